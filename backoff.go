@@ -0,0 +1,27 @@
+package genai
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// fullJitterBackoff 按 AWS 的 "full jitter" 策略计算第 retry 次重试前的延迟：
+// 在 [0, base*2^retry] 之间均匀随机，避免大量并发 goroutine 同时醒来重试造成惊群。
+func fullJitterBackoff(base time.Duration, retry int) time.Duration {
+	maxDelay := float64(base) * math.Pow(2, float64(retry))
+	return time.Duration(rand.Float64() * maxDelay)
+}
+
+// sleepWithContext 睡眠 d 或直至 ctx 被取消，取消时返回 ctx.Err()
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,27 @@
+package genai
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFullJitterBackoffBounds(t *testing.T) {
+	base := 100 * time.Millisecond
+	for retry := 0; retry < 5; retry++ {
+		maxDelay := time.Duration(float64(base) * pow2(retry))
+		for i := 0; i < 20; i++ {
+			got := fullJitterBackoff(base, retry)
+			if got < 0 || got > maxDelay {
+				t.Errorf("retry %d: fullJitterBackoff returned %v, want within [0, %v]", retry, got, maxDelay)
+			}
+		}
+	}
+}
+
+func pow2(n int) float64 {
+	result := 1.0
+	for i := 0; i < n; i++ {
+		result *= 2
+	}
+	return result
+}
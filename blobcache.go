@@ -0,0 +1,122 @@
+package genai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// BlobCacheTTL 近似 Gemini Files API 上传文件的生命周期（48 小时），缓存条目默认按此过期
+const BlobCacheTTL = 48 * time.Hour
+
+// CachedBlob 是一次成功上传后记下的 Vertex 文件引用
+type CachedBlob struct {
+	FileURI   string
+	MIMEType  string
+	ExpiresAt time.Time
+}
+
+// BlobCache 把 BlobInput 的缓存键（由路径和文件元数据构成，不是内容哈希）映射到已经上传过的
+// Vertex 文件引用，避免重复上传同一份资源
+type BlobCache interface {
+	Get(ctx context.Context, key string) (CachedBlob, bool, error)
+	Put(ctx context.Context, key string, blob CachedBlob) error
+}
+
+// MemoryBlobCache 是进程内的 BlobCache 实现，适合单实例部署或测试
+type MemoryBlobCache struct {
+	mu      sync.Mutex
+	entries map[string]CachedBlob
+}
+
+// NewMemoryBlobCache 创建一个进程内 BlobCache
+func NewMemoryBlobCache() *MemoryBlobCache {
+	return &MemoryBlobCache{entries: make(map[string]CachedBlob)}
+}
+
+func (c *MemoryBlobCache) Get(ctx context.Context, key string) (CachedBlob, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	blob, ok := c.entries[key]
+	if !ok {
+		return CachedBlob{}, false, nil
+	}
+	if time.Now().After(blob.ExpiresAt) {
+		delete(c.entries, key)
+		return CachedBlob{}, false, nil
+	}
+	return blob, true, nil
+}
+
+func (c *MemoryBlobCache) Put(ctx context.Context, key string, blob CachedBlob) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = blob
+	return nil
+}
+
+// GCSBlobCache 把缓存条目以 JSON 对象的形式存放在 GCS，适合多实例部署间共享
+type GCSBlobCache struct {
+	Bucket string
+	Prefix string
+}
+
+// NewGCSBlobCache 创建一个以 bucket/prefix 为存储位置的 BlobCache
+func NewGCSBlobCache(bucket, prefix string) *GCSBlobCache {
+	return &GCSBlobCache{Bucket: bucket, Prefix: prefix}
+}
+
+func (c *GCSBlobCache) objectName(key string) string {
+	return c.Prefix + key + ".json"
+}
+
+func (c *GCSBlobCache) Get(ctx context.Context, key string) (CachedBlob, bool, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return CachedBlob{}, false, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+	defer client.Close()
+
+	r, err := client.Bucket(c.Bucket).Object(c.objectName(key)).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return CachedBlob{}, false, nil
+	}
+	if err != nil {
+		return CachedBlob{}, false, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	defer r.Close()
+
+	var blob CachedBlob
+	if err := json.NewDecoder(r).Decode(&blob); err != nil {
+		return CachedBlob{}, false, fmt.Errorf("failed to decode cache entry: %w", err)
+	}
+	if time.Now().After(blob.ExpiresAt) {
+		return CachedBlob{}, false, nil
+	}
+	return blob, true, nil
+}
+
+func (c *GCSBlobCache) Put(ctx context.Context, key string, blob CachedBlob) error {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create GCS client: %w", err)
+	}
+	defer client.Close()
+
+	w := client.Bucket(c.Bucket).Object(c.objectName(key)).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if err := json.NewEncoder(w).Encode(blob); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finalize cache entry: %w", err)
+	}
+	return nil
+}
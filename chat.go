@@ -0,0 +1,286 @@
+package genai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ChatMessage 是会话历史里的一条消息
+type ChatMessage struct {
+	Role  string   `json:"role"` // "user" 或 "model"/"assistant"
+	Parts []string `json:"parts"`
+}
+
+// HistoryStore 负责持久化一个会话的消息历史
+type HistoryStore interface {
+	Load(ctx context.Context, sessionID string) ([]ChatMessage, error)
+	Save(ctx context.Context, sessionID string, history []ChatMessage) error
+	Clear(ctx context.Context, sessionID string) error
+}
+
+// MemoryHistoryStore 是进程内的 HistoryStore 实现，适合单实例部署或测试
+type MemoryHistoryStore struct {
+	sessions map[string][]ChatMessage
+}
+
+// NewMemoryHistoryStore 创建一个进程内 HistoryStore
+func NewMemoryHistoryStore() *MemoryHistoryStore {
+	return &MemoryHistoryStore{sessions: make(map[string][]ChatMessage)}
+}
+
+func (s *MemoryHistoryStore) Load(ctx context.Context, sessionID string) ([]ChatMessage, error) {
+	return append([]ChatMessage{}, s.sessions[sessionID]...), nil
+}
+
+func (s *MemoryHistoryStore) Save(ctx context.Context, sessionID string, history []ChatMessage) error {
+	s.sessions[sessionID] = append([]ChatMessage{}, history...)
+	return nil
+}
+
+func (s *MemoryHistoryStore) Clear(ctx context.Context, sessionID string) error {
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+// SummarizeFunc 把较早的历史压缩成一段摘要文本，通常由调用方借助模型自身实现
+type SummarizeFunc func(ctx context.Context, history []ChatMessage) (string, error)
+
+// ChatSession 在 GeminiAPI/ClaudeAPI 之上维护一个多轮会话
+type ChatSession struct {
+	SessionID         string
+	Gemini            *GeminiAPI
+	Claude            *ClaudeAPI
+	Store             HistoryStore
+	MaxTokens         int           // 历史的估算 token 数超过该预算后触发裁剪，0 表示不裁剪
+	Summarize         SummarizeFunc // 非空时用摘要替代滑动窗口丢弃的历史
+	SystemInstruction string        // 贯穿整个会话的系统指令
+	history           []ChatMessage
+}
+
+// NewChatSession 创建一个基于给定 HistoryStore 的会话。store 为 nil 时使用 MemoryHistoryStore。
+func NewChatSession(sessionID string, store HistoryStore) *ChatSession {
+	if store == nil {
+		store = NewMemoryHistoryStore()
+	}
+	return &ChatSession{SessionID: sessionID, Store: store}
+}
+
+// NewChat 基于该 GeminiAPI 创建一个进程内多轮会话，历史保存在 MemoryHistoryStore 中，
+// 需要跨进程持久化时改用 NewChatSession 并传入自定义 HistoryStore。
+func (a *GeminiAPI) NewChat(systemInstruction string) *ChatSession {
+	return &ChatSession{
+		Gemini:            a,
+		Store:             NewMemoryHistoryStore(),
+		SystemInstruction: systemInstruction,
+	}
+}
+
+// NewChat 基于该 ClaudeAPI 创建一个进程内多轮会话，历史保存在 MemoryHistoryStore 中，
+// 需要跨进程持久化时改用 NewChatSession 并传入自定义 HistoryStore。
+func (a *ClaudeAPI) NewChat(systemInstruction string) *ChatSession {
+	return &ChatSession{
+		Claude:            a,
+		Store:             NewMemoryHistoryStore(),
+		SystemInstruction: systemInstruction,
+	}
+}
+
+// SetSystemInstruction 更新会话的系统指令，对后续轮次生效
+func (c *ChatSession) SetSystemInstruction(systemInstruction string) {
+	c.SystemInstruction = systemInstruction
+}
+
+// History 返回当前已加载的消息历史
+func (c *ChatSession) History() []ChatMessage {
+	return append([]ChatMessage{}, c.history...)
+}
+
+// Reset 清空会话历史，包括底层 HistoryStore 中的记录
+func (c *ChatSession) Reset(ctx context.Context) error {
+	c.history = nil
+	return c.Store.Clear(ctx, c.SessionID)
+}
+
+func (c *ChatSession) load(ctx context.Context) error {
+	if c.history != nil {
+		return nil
+	}
+	history, err := c.Store.Load(ctx, c.SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+	c.history = history
+	return nil
+}
+
+// SendMessage 把 inputs 作为用户的一轮输入追加到历史中，调用底层 LLM 并把回复也记入历史。
+// 必须设置 Gemini 或 Claude 之一。
+func (c *ChatSession) SendMessage(ctx context.Context, inputs ...Input) (string, error) {
+	if err := c.load(ctx); err != nil {
+		return "", err
+	}
+
+	userText := inputsToText(inputs)
+	c.history = append(c.history, ChatMessage{Role: "user", Parts: []string{userText}})
+
+	// c.history 此时已经包含了本轮的用户输入，invokeChat 只需要之前的轮次作为上下文，
+	// 否则模型每轮都会失去之前的对话记忆
+	priorHistory := c.history[:len(c.history)-1]
+
+	var reply string
+	var err error
+	switch {
+	case c.Gemini != nil:
+		reply, err = c.Gemini.invokeChat(ctx, c.SystemInstruction, priorHistory, inputs...)
+	case c.Claude != nil:
+		reply, err = c.Claude.invokeChat(ctx, c.SystemInstruction, priorHistory, []string{userText}, nil)
+	default:
+		return "", fmt.Errorf("chat session has no backing LLM configured")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	c.history = append(c.history, ChatMessage{Role: "model", Parts: []string{reply}})
+
+	if err := c.trim(ctx); err != nil {
+		return "", err
+	}
+	if err := c.Store.Save(ctx, c.SessionID, c.history); err != nil {
+		return "", fmt.Errorf("failed to save history: %w", err)
+	}
+
+	return reply, nil
+}
+
+// SendMessageStream 和 SendMessage 类似，但以流式方式返回回复；完整回复在流结束后才会
+// 写入历史并持久化，因此 History() 在流读完之前不包含本轮输出。
+func (c *ChatSession) SendMessageStream(ctx context.Context, inputs ...Input) (<-chan StreamChunk, error) {
+	if err := c.load(ctx); err != nil {
+		return nil, err
+	}
+
+	userText := inputsToText(inputs)
+	c.history = append(c.history, ChatMessage{Role: "user", Parts: []string{userText}})
+	priorHistory := c.history[:len(c.history)-1]
+
+	var upstream <-chan StreamChunk
+	var err error
+	switch {
+	case c.Gemini != nil:
+		upstream, err = c.Gemini.invokeChatStream(ctx, c.SystemInstruction, priorHistory, inputs...)
+	case c.Claude != nil:
+		messages, buildErr := c.Claude.buildMessages([]string{userText}, nil)
+		if buildErr != nil {
+			return nil, buildErr
+		}
+		upstream, err = c.Claude.invokeChatStream(ctx, c.SystemInstruction, priorHistory, messages)
+	default:
+		return nil, fmt.Errorf("chat session has no backing LLM configured")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+
+		var reply string
+		for chunk := range upstream {
+			reply += chunk.Text
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+			if chunk.Err != nil {
+				return
+			}
+		}
+
+		c.history = append(c.history, ChatMessage{Role: "model", Parts: []string{reply}})
+		if err := c.trim(ctx); err != nil {
+			return
+		}
+		_ = c.Store.Save(ctx, c.SessionID, c.history)
+	}()
+
+	return out, nil
+}
+
+// inputsToText 拼接一组 Input 中的文本部分，供历史记录和 Claude 的纯文本调用使用
+func inputsToText(inputs []Input) string {
+	var text string
+	for _, input := range inputs {
+		if t, ok := input.(TextInput); ok {
+			text += t.Text
+		}
+	}
+	return text
+}
+
+// Rewind 撤销最近一轮对话（一条用户消息加一条模型回复），常用于重新生成上一次回答
+func (c *ChatSession) Rewind() {
+	if len(c.history) >= 2 {
+		c.history = c.history[:len(c.history)-2]
+	} else {
+		c.history = nil
+	}
+}
+
+// historyTokens 粗略估算一段历史消耗的 token 数，供 trim 判断是否超出 MaxTokens 预算
+func historyTokens(history []ChatMessage) int {
+	var parts []string
+	for _, m := range history {
+		parts = append(parts, m.Parts...)
+	}
+	return estimateTokens(parts)
+}
+
+// trim 在历史的估算 token 数超过 MaxTokens 时裁剪最旧的消息，优先使用 Summarize 压缩而非
+// 直接丢弃；至少保留最近一轮（一条 user + 一条 model），避免把当前对话也一并裁掉
+func (c *ChatSession) trim(ctx context.Context) error {
+	if c.MaxTokens <= 0 || historyTokens(c.history) <= c.MaxTokens {
+		return nil
+	}
+
+	cut := 0
+	for cut < len(c.history)-2 && historyTokens(c.history[cut:]) > c.MaxTokens {
+		cut++
+	}
+	if cut == 0 {
+		return nil
+	}
+
+	overflow := c.history[:cut]
+	rest := c.history[cut:]
+
+	if c.Summarize == nil {
+		c.history = rest
+		return nil
+	}
+
+	summary, err := c.Summarize(ctx, overflow)
+	if err != nil {
+		return fmt.Errorf("failed to summarize history: %w", err)
+	}
+	c.history = append([]ChatMessage{{Role: "user", Parts: []string{summary}}}, rest...)
+	return nil
+}
+
+// MarshalJSON 辅助序列化历史，便于持久化实现复用
+func (c *ChatSession) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.history)
+}
+
+// UnmarshalJSON 把之前 MarshalJSON 导出的 transcript 载回会话，覆盖当前历史
+func (c *ChatSession) UnmarshalJSON(data []byte) error {
+	var history []ChatMessage
+	if err := json.Unmarshal(data, &history); err != nil {
+		return fmt.Errorf("failed to unmarshal history: %w", err)
+	}
+	c.history = history
+	return nil
+}
@@ -0,0 +1,68 @@
+package genai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestChatSessionTrimByTokenBudget(t *testing.T) {
+	c := &ChatSession{MaxTokens: 20}
+	for i := 0; i < 5; i++ {
+		c.history = append(c.history,
+			ChatMessage{Role: "user", Parts: []string{strings.Repeat("x", 40)}},
+			ChatMessage{Role: "model", Parts: []string{strings.Repeat("y", 40)}},
+		)
+	}
+
+	if err := c.trim(context.Background()); err != nil {
+		t.Fatalf("trim returned error: %v", err)
+	}
+
+	if got := historyTokens(c.history); got > c.MaxTokens {
+		t.Errorf("history tokens after trim = %d, want <= %d", got, c.MaxTokens)
+	}
+	if len(c.history) < 2 {
+		t.Errorf("trim should keep at least the most recent turn, got %d messages", len(c.history))
+	}
+}
+
+func TestChatSessionTrimDisabledWhenMaxTokensZero(t *testing.T) {
+	c := &ChatSession{}
+	c.history = append(c.history, ChatMessage{Role: "user", Parts: []string{strings.Repeat("x", 1000)}})
+
+	if err := c.trim(context.Background()); err != nil {
+		t.Fatalf("trim returned error: %v", err)
+	}
+	if len(c.history) != 1 {
+		t.Errorf("expected trim to be a no-op when MaxTokens is 0, got %d messages", len(c.history))
+	}
+}
+
+func TestChatSessionTrimSummarizesOverflow(t *testing.T) {
+	var summarized []ChatMessage
+	c := &ChatSession{
+		MaxTokens: 20,
+		Summarize: func(ctx context.Context, history []ChatMessage) (string, error) {
+			summarized = history
+			return "summary", nil
+		},
+	}
+	for i := 0; i < 5; i++ {
+		c.history = append(c.history,
+			ChatMessage{Role: "user", Parts: []string{strings.Repeat("x", 40)}},
+			ChatMessage{Role: "model", Parts: []string{strings.Repeat("y", 40)}},
+		)
+	}
+
+	if err := c.trim(context.Background()); err != nil {
+		t.Fatalf("trim returned error: %v", err)
+	}
+
+	if len(summarized) == 0 {
+		t.Fatalf("expected Summarize to be called with the overflowing history")
+	}
+	if c.history[0].Parts[0] != "summary" {
+		t.Errorf("expected trimmed history to start with the summary, got %+v", c.history[0])
+	}
+}
@@ -7,13 +7,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math"
+	"mime"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"cloud.google.com/go/vertexai/genai"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
@@ -48,20 +50,40 @@ type ImageSource struct {
 	Data      string `json:"data"`
 }
 
+// ContentDocument 是 Claude 3.5 在 Vertex 上支持的 PDF 文档内容块
+type ContentDocument struct {
+	Type   string         `json:"type"`
+	Source DocumentSource `json:"source"`
+}
+
+type DocumentSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
 // ClaudeRequest 定义请求结构
 type ClaudeRequest struct {
 	AnthropicVersion string                   `json:"anthropic_version"`
+	Model            string                   `json:"model,omitempty"` // 直连 Anthropic API 需要，Vertex rawPredict 的 URL 已经指定了模型，不需要这个字段
 	Messages         []map[string]interface{} `json:"messages"`
 	System           string                   `json:"system,omitempty"`
 	Temperature      float32                  `json:"temperature"`
 	MaxTokens        int                      `json:"max_tokens"`
 	TopP             float32                  `json:"top_p"`
 	TopK             int                      `json:"top_k"`
+	Stream           bool                     `json:"stream,omitempty"`
+	Tools            []ClaudeTool             `json:"tools,omitempty"`
+	ToolChoice       map[string]any           `json:"tool_choice,omitempty"`
 }
 
 // ClaudeResponse 定义响应结构
 type ClaudeResponse struct {
 	Content []map[string]interface{} `json:"content"`
+	Usage   struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
 }
 
 type ClaudeAPI struct {
@@ -74,6 +96,29 @@ type ClaudeAPI struct {
 	MaxRetries  int
 	RetryDelay  time.Duration
 	httpClient  *http.Client // 复用 http client
+	Limiter     *Limiter     // 非空时对 Invoke 和会话的 SendMessage 调用做限速和用量统计
+
+	// ResponseSchema 非空时，InvokeInto 会通过强制工具调用确保 Claude 返回符合该 schema 的 JSON
+	ResponseSchema *genai.Schema
+}
+
+// WithLimiter 挂载一个 Limiter，使该 ClaudeAPI 的 Invoke 和会话的 SendMessage 调用受限速和用量统计约束；
+// InvokeWithTools、InvokeInto 和流式调用不经过 Limiter
+func (a *ClaudeAPI) WithLimiter(l *Limiter) *ClaudeAPI {
+	a.Limiter = l
+	return a
+}
+
+// WithResponseSchema 为 InvokeInto 设置目标 JSON Schema，通过强制调用单个工具来保证输出符合结构
+func (a *ClaudeAPI) WithResponseSchema(schema *genai.Schema) *ClaudeAPI {
+	a.ResponseSchema = schema
+	return a
+}
+
+// WithResponseStruct 和 WithResponseSchema 类似，但通过反射从 v 的 json tag 推导 schema，
+// v 通常传入目标结构体的零值指针，例如 &MyStruct{}
+func (a *ClaudeAPI) WithResponseStruct(v any) *ClaudeAPI {
+	return a.WithResponseSchema(structToSchema(v))
 }
 
 // NewAPI 创建 API 实例
@@ -99,6 +144,12 @@ func (a *ClaudeAPI) initHttpClient() {
 }
 
 func (a *ClaudeAPI) buildMessages(prompts []string, img_paths []string) ([]*Message, error) {
+	return buildClaudeMessages(prompts, img_paths)
+}
+
+// buildClaudeMessages 把文本和图片/PDF 输入组装成 Anthropic Messages API 的一条 user 消息。
+// 不依赖具体 ClaudeAPI 实例，Vertex 和直连 Anthropic 的实现都可以复用。
+func buildClaudeMessages(prompts []string, img_paths []string) ([]*Message, error) {
 	var messages []*Message
 	var contents []Contents
 	for _, prompt := range prompts {
@@ -109,16 +160,35 @@ func (a *ClaudeAPI) buildMessages(prompts []string, img_paths []string) ([]*Mess
 	}
 
 	for _, img_path := range img_paths {
-		bytes, err := os.ReadFile(img_path)
+		data, err := os.ReadFile(img_path)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read image file %s: %w", img_path, err)
+			return nil, fmt.Errorf("failed to read file %s: %w", img_path, err)
+		}
+
+		mimeType := http.DetectContentType(data)
+		if mimeType == "application/octet-stream" { // fallback to extension
+			mimeType = mime.TypeByExtension(filepath.Ext(img_path))
 		}
+		encoded := base64.StdEncoding.EncodeToString(data)
+
+		if mimeType == "application/pdf" {
+			contents = append(contents, &ContentDocument{
+				Type: "document",
+				Source: DocumentSource{
+					Type:      "base64",
+					MediaType: mimeType,
+					Data:      encoded,
+				},
+			})
+			continue
+		}
+
 		contents = append(contents, &ContentImage{
 			Type: "image",
 			Source: ImageSource{
 				Type:      "base64",
-				MediaType: "image/jpeg",
-				Data:      base64.StdEncoding.EncodeToString(bytes),
+				MediaType: mimeType,
+				Data:      encoded,
 			},
 		})
 	}
@@ -130,13 +200,14 @@ func (a *ClaudeAPI) buildMessages(prompts []string, img_paths []string) ([]*Mess
 	return messages, nil
 }
 
-func (a *ClaudeAPI) Invoke(prompts []string, img_paths []string) (string, error) {
+// Invoke 调用 Claude 模型。调用方通过 ctx 控制超时/取消；每次 HTTP 请求另受 httpClient 的 60s 超时限制。
+func (a *ClaudeAPI) Invoke(ctx context.Context, prompts []string, img_paths []string) (string, error) {
 	messages, err := a.buildMessages(prompts, img_paths)
 	if err != nil {
 		return "", err
 	}
 
-	resp, err := a.invokeMessages(messages)
+	resp, err := a.invokeMessages(ctx, messages)
 	if err != nil {
 		return "", err
 	}
@@ -144,8 +215,58 @@ func (a *ClaudeAPI) Invoke(prompts []string, img_paths []string) (string, error)
 	return resp, nil
 }
 
+// invokeChat 和 Invoke 相同，但会把 history 之前的轮次重放给模型，并在 systemInstruction
+// 非空时把它作为一条 system 消息前置，供 ChatSession 使用
+func (a *ClaudeAPI) invokeChat(ctx context.Context, systemInstruction string, history []ChatMessage, prompts []string, img_paths []string) (string, error) {
+	messages, err := a.buildMessages(prompts, img_paths)
+	if err != nil {
+		return "", err
+	}
+	messages = append(chatHistoryToClaudeMessages(history), messages...)
+	if systemInstruction != "" {
+		messages = append([]*Message{{
+			Role:     "system",
+			Contents: []Contents{&ContentText{Type: "text", Text: systemInstruction}},
+		}}, messages...)
+	}
+
+	return a.invokeMessages(ctx, messages)
+}
+
+// chatHistoryToClaudeMessages 把 ChatSession 维护的历史转换成 Anthropic Messages API 需要的
+// Message 列表，"model" 统一映射成 Claude 的 "assistant" 角色
+func chatHistoryToClaudeMessages(history []ChatMessage) []*Message {
+	out := make([]*Message, 0, len(history))
+	for _, m := range history {
+		role := m.Role
+		if role == "model" {
+			role = "assistant"
+		}
+		contents := make([]Contents, 0, len(m.Parts))
+		for _, p := range m.Parts {
+			contents = append(contents, &ContentText{Type: "text", Text: p})
+		}
+		out = append(out, &Message{Role: role, Contents: contents})
+	}
+	return out
+}
+
+// messagesToPrompts 抽取一组 Message 里的文本内容，供 estimateTokens 粗略估算请求体积使用，
+// 图片/文档块不计入（它们的 token 成本不能用字符数近似）。
+func messagesToPrompts(messages []*Message) []string {
+	var prompts []string
+	for _, m := range messages {
+		for _, c := range m.Contents {
+			if t, ok := c.(*ContentText); ok {
+				prompts = append(prompts, t.Text)
+			}
+		}
+	}
+	return prompts
+}
+
 // CluadeInvokeMessages 调用 Claude API
-func (a *ClaudeAPI) invokeMessages(messages []*Message) (string, error) {
+func (a *ClaudeAPI) invokeMessages(ctx context.Context, messages []*Message) (string, error) {
 	request, err := a.buildClaudeRequest(messages)
 	if err != nil {
 		return "", fmt.Errorf("failed to build request: %w", err)
@@ -156,9 +277,20 @@ func (a *ClaudeAPI) invokeMessages(messages []*Message) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	output, err := a.callClaudeModel(payloadBytes)
+	if a.Limiter != nil {
+		if err := a.Limiter.Acquire(ctx, a.ProjectID, a.Model, estimateTokens(messagesToPrompts(messages))); err != nil {
+			return "", fmt.Errorf("rate limiter: %w", err)
+		}
+	}
+
+	start := time.Now()
+	output, err := a.callClaudeModel(ctx, payloadBytes)
 	if err != nil {
-		return "", fmt.Errorf("failed to call claude model: %w", err)
+		apiErr := parseAPIError(err, "")
+		if a.Limiter != nil && apiErr.IsQuotaExceeded() {
+			a.Limiter.OnThrottled(a.ProjectID, a.Model)
+		}
+		return "", fmt.Errorf("failed to call claude model: %w", apiErr)
 	}
 
 	var resp ClaudeResponse
@@ -166,7 +298,11 @@ func (a *ClaudeAPI) invokeMessages(messages []*Message) (string, error) {
 		return "", fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	contentStr, err := a.parseResponseContent(resp)
+	if a.Limiter != nil {
+		a.Limiter.Record(a.ProjectID, a.Model, resp.Usage.InputTokens, resp.Usage.OutputTokens, time.Since(start))
+	}
+
+	contentStr, err := parseClaudeResponseContent(resp)
 
 	if err != nil {
 		return "", fmt.Errorf("failed to parse response content: %w", err)
@@ -176,9 +312,14 @@ func (a *ClaudeAPI) invokeMessages(messages []*Message) (string, error) {
 }
 
 func (a *ClaudeAPI) buildClaudeRequest(messages []*Message) (ClaudeRequest, error) {
+	return newClaudeRequest(a.Temperature, messages)
+}
+
+// newClaudeRequest 把一组 Message 组装成一次 Anthropic Messages API 请求体，不绑定具体 provider。
+func newClaudeRequest(temperature float32, messages []*Message) (ClaudeRequest, error) {
 	request := ClaudeRequest{
 		AnthropicVersion: anthropicVersion,
-		Temperature:      a.Temperature,
+		Temperature:      temperature,
 		MaxTokens:        1024,
 		TopP:             0.95,
 		TopK:             40,
@@ -186,7 +327,7 @@ func (a *ClaudeAPI) buildClaudeRequest(messages []*Message) (ClaudeRequest, erro
 
 	for _, msg := range messages {
 		if msg.Role == "system" {
-			systemText, err := a.extractSystemText(msg.Contents)
+			systemText, err := extractSystemText(msg.Contents)
 			if err != nil {
 				return ClaudeRequest{}, err
 			}
@@ -202,7 +343,7 @@ func (a *ClaudeAPI) buildClaudeRequest(messages []*Message) (ClaudeRequest, erro
 	return request, nil
 }
 
-func (a *ClaudeAPI) extractSystemText(contents []Contents) (string, error) {
+func extractSystemText(contents []Contents) (string, error) {
 	for _, content := range contents {
 		if textContent, ok := content.(*ContentText); ok {
 			return textContent.Text, nil
@@ -211,7 +352,7 @@ func (a *ClaudeAPI) extractSystemText(contents []Contents) (string, error) {
 	return "", fmt.Errorf("system message must contain text content")
 }
 
-func (a *ClaudeAPI) parseResponseContent(resp ClaudeResponse) (string, error) {
+func parseClaudeResponseContent(resp ClaudeResponse) (string, error) {
 	var contents []string
 	for _, v := range resp.Content {
 		if v["type"] == "text" {
@@ -226,35 +367,53 @@ func (a *ClaudeAPI) parseResponseContent(resp ClaudeResponse) (string, error) {
 	return strings.Join(contents, "\n"), nil
 }
 
-// callClaudeModel 调用 Claude 模型
-func (a *ClaudeAPI) callClaudeModel(payloadBytes []byte) ([]byte, error) {
+// callClaudeModel 调用 Claude 模型，ctx 取消时立即返回，重试间隔使用 full jitter 退避，
+// 并优先采用服务端 Retry-After 头给出的建议间隔
+func (a *ClaudeAPI) callClaudeModel(ctx context.Context, payloadBytes []byte) ([]byte, error) {
 	var body []byte
 	var err error
+	var retryAfter string
 	for i := 0; i <= a.MaxRetries; i++ {
-		body, err = a.callClaudeModelInternal(payloadBytes)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		body, retryAfter, err = a.callClaudeModelInternal(ctx, payloadBytes)
 		if err == nil {
 			return body, nil
 		}
+
+		apiErr := parseAPIError(err, retryAfter)
+		if !apiErr.IsQuotaExceeded() && !apiErr.IsTransient() {
+			return nil, apiErr
+		}
 		if i == a.MaxRetries {
-			return nil, fmt.Errorf("failed after %d retries: %w", a.MaxRetries, err)
+			return nil, fmt.Errorf("failed after %d retries: %w", a.MaxRetries, apiErr)
+		}
+
+		delay := apiErr.RetryAfter
+		if delay == 0 {
+			delay = fullJitterBackoff(a.RetryDelay, i)
+		}
+		if sleepErr := sleepWithContext(ctx, delay); sleepErr != nil {
+			return nil, sleepErr
 		}
-		time.Sleep(a.RetryDelay * time.Duration(math.Pow(2, float64(i)))) // 指数退避
 	}
 	return nil, errors.New("unreachable")
-
 }
 
-func (a *ClaudeAPI) callClaudeModelInternal(payloadBytes []byte) ([]byte, error) {
+// callClaudeModelInternal 发起一次 HTTP 请求，返回响应体、Retry-After 头（如果有）和错误
+func (a *ClaudeAPI) callClaudeModelInternal(ctx context.Context, payloadBytes []byte) ([]byte, string, error) {
 	token, err := a.getAccessToken()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get access token: %w", err)
+		return nil, "", fmt.Errorf("failed to get access token: %w", err)
 	}
 
 	apiURL := fmt.Sprintf(apiURLFormat, a.Location, a.ProjectID, a.Location, a.Model)
 
-	request, err := http.NewRequestWithContext(context.Background(), http.MethodPost, apiURL, strings.NewReader(string(payloadBytes)))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, strings.NewReader(string(payloadBytes)))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	request.Header.Set("Authorization", "Bearer "+token)
@@ -262,20 +421,20 @@ func (a *ClaudeAPI) callClaudeModelInternal(payloadBytes []byte) ([]byte, error)
 
 	response, err := a.httpClient.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf("http request failed: %w", err)
+		return nil, "", fmt.Errorf("http request failed: %w", err)
 	}
 	defer response.Body.Close()
 
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, "", fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d, response body: %s", response.StatusCode, string(body))
+		return nil, response.Header.Get("Retry-After"), fmt.Errorf("unexpected status code: %d, response body: %s", response.StatusCode, string(body))
 	}
 
-	return body, nil
+	return body, "", nil
 }
 
 // getAccessToken 获取 Google Cloud OAuth token
@@ -0,0 +1,229 @@
+package genai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/vertexai/genai"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// embedPredictURLFormat 是 Vertex AI embedding 模型的 predict 端点，vertexai/genai 客户端
+// 没有内置的 embedding 支持，所以这里和 claude.go 一样直接发 REST 请求
+const embedPredictURLFormat = "https://%v-aiplatform.googleapis.com/v1/projects/%v/locations/%v/publishers/google/models/%v:predict"
+
+// EmbeddingAPI 调用 Vertex 的文本/多模态 embedding 模型
+type EmbeddingAPI struct {
+	ModelName  string // 例如 "text-embedding-004" 或 "multimodalembedding@001"
+	ProjectID  string
+	Location   string
+	Client     *genai.Client
+	MaxRetries int
+	RetryDelay time.Duration
+
+	TokenMtx   sync.Mutex
+	Token      *oauth2.Token
+	httpClient *http.Client
+}
+
+// NewEmbeddingAPI 创建一个 EmbeddingAPI 实例
+func NewEmbeddingAPI(location, projectID, model string, maxRetries int, retryDelay time.Duration) *EmbeddingAPI {
+	return &EmbeddingAPI{
+		Location:   location,
+		ModelName:  model,
+		ProjectID:  projectID,
+		MaxRetries: maxRetries,
+		RetryDelay: retryDelay,
+	}
+}
+
+// InitClient 初始化 HTTP client，避免每次调用都创建
+func (a *EmbeddingAPI) InitClient(ctx context.Context) error {
+	if a.httpClient == nil {
+		a.httpClient = &http.Client{
+			Timeout: 60 * time.Second,
+		}
+	}
+	return nil
+}
+
+// embedInstance 是 predict 请求里 instances 数组的一项，Content 和 Image 互斥
+type embedInstance struct {
+	Content string           `json:"content,omitempty"`
+	Image   *embedImageField `json:"image,omitempty"`
+}
+
+type embedImageField struct {
+	BytesBase64Encoded string `json:"bytesBase64Encoded"`
+}
+
+type embedPredictRequest struct {
+	Instances []embedInstance `json:"instances"`
+}
+
+type embedPredictResponse struct {
+	Predictions []struct {
+		Embeddings struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	} `json:"predictions"`
+}
+
+// Embed 为一批文本计算 embedding 向量
+func (a *EmbeddingAPI) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	instances := make([]embedInstance, 0, len(texts))
+	for _, text := range texts {
+		instances = append(instances, embedInstance{Content: text})
+	}
+
+	resp, err := a.predict(ctx, instances)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed content: %w", err)
+	}
+
+	out := make([][]float32, 0, len(resp.Predictions))
+	for _, p := range resp.Predictions {
+		out = append(out, p.Embeddings.Values)
+	}
+	return out, nil
+}
+
+// EmbedImage 为一批本地图片路径计算 embedding 向量
+func (a *EmbeddingAPI) EmbedImage(ctx context.Context, paths []string) ([][]float32, error) {
+	out := make([][]float32, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image file %s: %w", path, err)
+		}
+		_ = mimeTypeOf(path, data) // 多模态 embedding 的 predict 接口不需要 MIME 类型，探测结果未被使用
+
+		resp, err := a.predict(ctx, []embedInstance{{
+			Image: &embedImageField{BytesBase64Encoded: base64.StdEncoding.EncodeToString(data)},
+		}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed image %s: %w", path, err)
+		}
+		if len(resp.Predictions) == 0 {
+			return nil, fmt.Errorf("no embedding returned for image %s", path)
+		}
+		out = append(out, resp.Predictions[0].Embeddings.Values)
+	}
+	return out, nil
+}
+
+// mimeTypeOf 探测文件的 MIME 类型，探测失败时回退到按扩展名猜测
+func mimeTypeOf(path string, data []byte) string {
+	mimeType := http.DetectContentType(data)
+	if mimeType == "application/octet-stream" {
+		mimeType = mime.TypeByExtension(filepath.Ext(path))
+	}
+	return mimeType
+}
+
+// predict 带重试地向 Vertex AI 的 predict 端点发起一次 embedding 请求
+func (a *EmbeddingAPI) predict(ctx context.Context, instances []embedInstance) (*embedPredictResponse, error) {
+	if err := a.InitClient(ctx); err != nil {
+		return nil, err
+	}
+
+	payloadBytes, err := json.Marshal(embedPredictRequest{Instances: instances})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var body []byte
+	for retry := 0; ; retry++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		body, err = a.callPredictInternal(ctx, payloadBytes)
+		if err == nil {
+			break
+		}
+
+		apiErr := parseAPIError(err, "")
+		if !apiErr.IsQuotaExceeded() && !apiErr.IsTransient() {
+			return nil, apiErr
+		}
+		if retry >= a.MaxRetries {
+			return nil, fmt.Errorf("max retries reached after %d attempts, last error: %w", a.MaxRetries+1, apiErr)
+		}
+		if sleepErr := sleepWithContext(ctx, fullJitterBackoff(a.RetryDelay, retry)); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+
+	var resp embedPredictResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &resp, nil
+}
+
+// callPredictInternal 发起一次 HTTP 请求，返回响应体
+func (a *EmbeddingAPI) callPredictInternal(ctx context.Context, payloadBytes []byte) ([]byte, error) {
+	token, err := a.getAccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	apiURL := fmt.Sprintf(embedPredictURLFormat, a.Location, a.ProjectID, a.Location, a.ModelName)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, strings.NewReader(string(payloadBytes)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Content-Type", contentType)
+
+	response, err := a.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d, response body: %s", response.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// getAccessToken 获取 Google Cloud OAuth token
+func (a *EmbeddingAPI) getAccessToken() (string, error) {
+	a.TokenMtx.Lock()
+	defer a.TokenMtx.Unlock()
+
+	if a.Token != nil && !a.Token.Expiry.Before(time.Now()) {
+		return a.Token.AccessToken, nil
+	}
+
+	creds, err := google.FindDefaultCredentials(context.Background(), "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return "", err
+	}
+
+	token, err := creds.TokenSource.Token()
+	if err != nil {
+		return "", err
+	}
+
+	a.Token = token
+	return token.AccessToken, nil
+}
@@ -0,0 +1,110 @@
+package genai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError is a parsed Vertex error response, letting callers branch on the
+// underlying HTTP/gRPC status instead of regex-matching a formatted string.
+type APIError struct {
+	Code       int           // HTTP 状态码，0 表示未知
+	Status     string        // gRPC 状态名，例如 "RESOURCE_EXHAUSTED"
+	Message    string        // 原始错误消息
+	RetryAfter time.Duration // 从 Retry-After 头解析出的建议重试间隔，0 表示没有
+	Details    string        // 原始响应体，便于排查
+}
+
+func (e *APIError) Error() string {
+	if e.Status != "" {
+		return fmt.Sprintf("vertex api error: %d %s: %s", e.Code, e.Status, e.Message)
+	}
+	return fmt.Sprintf("vertex api error: %d: %s", e.Code, e.Message)
+}
+
+// IsQuotaExceeded 报告该错误是否为配额/速率限制类错误（HTTP 429 或 RESOURCE_EXHAUSTED）
+func (e *APIError) IsQuotaExceeded() bool {
+	return e.Code == 429 || e.Status == "RESOURCE_EXHAUSTED"
+}
+
+// IsTransient 报告该错误是否为可重试的瞬时错误（5xx）
+func (e *APIError) IsTransient() bool {
+	return e.Code >= 500 && e.Code < 600
+}
+
+// IsSafetyBlock 报告该错误是否因安全策略拦截而产生
+func (e *APIError) IsSafetyBlock() bool {
+	return strings.Contains(strings.ToLower(e.Message), "finishreasonsafety") ||
+		strings.Contains(strings.ToLower(e.Message), "safety")
+}
+
+// vertexErrorBody 是 Vertex/Google API 错误响应体里 "error" 字段的形状
+type vertexErrorBody struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// parseAPIError 尝试把底层错误里的 Vertex JSON 错误体解析成 *APIError；没有可解析的错误体时
+// 退回到从消息文本中提取 HTTP 状态码，Message/Details 保留原始消息，让调用方始终能以统一的类型处理错误。
+func parseAPIError(err error, retryAfterHeader string) *APIError {
+	if err == nil {
+		return nil
+	}
+
+	apiErr := &APIError{Message: err.Error(), Details: err.Error()}
+
+	var body vertexErrorBody
+	if jsonErr := json.Unmarshal([]byte(extractJSONBody(err.Error())), &body); jsonErr == nil && body.Error.Code != 0 {
+		apiErr.Code = body.Error.Code
+		apiErr.Status = body.Error.Status
+		apiErr.Message = body.Error.Message
+	} else if code, ok := extractStatusCode(err.Error()); ok {
+		apiErr.Code = code
+	}
+
+	if retryAfterHeader != "" {
+		if secs, convErr := strconv.Atoi(retryAfterHeader); convErr == nil {
+			apiErr.RetryAfter = time.Duration(secs) * time.Second
+		}
+	}
+
+	return apiErr
+}
+
+// extractJSONBody 从形如 "... : {\"error\": {...}}" 的错误字符串里取出可能存在的 JSON 片段
+func extractJSONBody(msg string) string {
+	idx := strings.Index(msg, "{")
+	if idx == -1 {
+		return ""
+	}
+	return msg[idx:]
+}
+
+// extractStatusCode 从 "unexpected status code: 429, ..." 这类消息里提取 HTTP 状态码；
+// 消息里没有 "status code: " 时，退回到在整条消息中查找几个常见的 429/5xx 状态码
+func extractStatusCode(msg string) (int, bool) {
+	const marker = "status code: "
+	if idx := strings.Index(msg, marker); idx != -1 {
+		rest := msg[idx+len(marker):]
+		end := 0
+		for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+			end++
+		}
+		if n, err := strconv.Atoi(rest[:end]); err == nil {
+			return n, true
+		}
+	}
+	for _, code := range []string{"429", "500", "502", "503", "529"} {
+		if strings.Contains(msg, code) {
+			n, err := strconv.Atoi(code)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
@@ -2,9 +2,9 @@ package genai
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"math"
 	"mime"
 	"net/http"
 	"net/url"
@@ -27,6 +27,8 @@ type GeminiAPI struct {
 	Temperature      float32
 	MaxRetries       int           // 最大重试次数
 	RetryDelay       time.Duration // 初始重试延迟
+	Limiter          *Limiter      // 非空时对每次非流式调用做限速和用量统计
+	BlobCache        BlobCache     // 非空时复用已转存到 StagingBucket 的 BlobInput 文件引用
 }
 
 // NewAPI 创建 API 实例
@@ -54,28 +56,130 @@ func (a *GeminiAPI) InitClient(ctx context.Context) error {
 	return nil
 }
 
-// 重试函数
-func (a *GeminiAPI) retryableGenerateContent(ctx context.Context, model *genai.GenerativeModel, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+// partsToPrompts 抽取一组 genai.Part 里的文本部分，供 estimateTokens 粗略估算请求体积使用，
+// BlobPart/FileData 等非文本 part 不计入（它们的 token 成本不能用字符数近似）。
+func partsToPrompts(parts []genai.Part) []string {
+	var prompts []string
+	for _, p := range parts {
+		if t, ok := p.(genai.Text); ok {
+			prompts = append(prompts, string(t))
+		}
+	}
+	return prompts
+}
+
+// retryableGenerateContent 在 ctx 取消时立即返回，并用 full jitter 退避避免惊群。
+// send 封装实际发起的一次调用（单轮 model.GenerateContent 或多轮 ChatSession.SendMessage），
+// 重试时会被原样再次调用，调用方需要保证重复调用是幂等的（不会重复追加历史）。
+// estimatedTokens 是调用前对本次请求大小的粗略估算，用于 Limiter 的 TPM 预算限速。
+func (a *GeminiAPI) retryableGenerateContent(ctx context.Context, estimatedTokens int, send func(ctx context.Context) (*genai.GenerateContentResponse, error)) (*genai.GenerateContentResponse, error) {
 	var resp *genai.GenerateContentResponse
 	var err error
 	for retry := 0; retry <= a.MaxRetries; retry++ {
-		resp, err = model.GenerateContent(ctx, parts...)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if a.Limiter != nil {
+			if limitErr := a.Limiter.Acquire(ctx, a.ProjectID, a.ModelName, estimatedTokens); limitErr != nil {
+				return nil, fmt.Errorf("rate limiter: %w", limitErr)
+			}
+		}
+
+		start := time.Now()
+		resp, err = send(ctx)
+
+		if a.Limiter != nil {
+			if err == nil && resp != nil && resp.UsageMetadata != nil {
+				a.Limiter.Record(a.ProjectID, a.ModelName, int(resp.UsageMetadata.PromptTokenCount), int(resp.UsageMetadata.CandidatesTokenCount), time.Since(start))
+			}
+		}
+
 		if err == nil {
 			break
 		}
+
+		apiErr := parseAPIError(err, "")
+		if a.Limiter != nil && apiErr.IsQuotaExceeded() {
+			a.Limiter.OnThrottled(a.ProjectID, a.ModelName)
+		}
+		if !apiErr.IsQuotaExceeded() && !apiErr.IsTransient() {
+			return nil, fmt.Errorf("generate content failed: %w", apiErr)
+		}
+
 		if retry < a.MaxRetries {
-			delay := a.RetryDelay * time.Duration(math.Pow(2, float64(retry))) // 指数退避
-			fmt.Printf("Retrying after %v, attempt %d/%d, error: %v\n", delay, retry+1, a.MaxRetries, err)
-			time.Sleep(delay)
+			delay := apiErr.RetryAfter
+			if delay == 0 {
+				delay = fullJitterBackoff(a.RetryDelay, retry)
+			}
+			fmt.Printf("Retrying after %v, attempt %d/%d, error: %v\n", delay, retry+1, a.MaxRetries, apiErr)
+			if sleepErr := sleepWithContext(ctx, delay); sleepErr != nil {
+				return nil, sleepErr
+			}
 			continue
-		} else {
-			// 达到最大重试次数，返回错误
-			return nil, fmt.Errorf("max retries reached after %d attempts, last error: %w", a.MaxRetries, err)
 		}
+		// 达到最大重试次数，返回错误
+		return nil, fmt.Errorf("max retries reached after %d attempts, last error: %w", a.MaxRetries+1, apiErr)
 	}
 	return resp, nil
 }
 
+// WithLimiter 挂载一个 Limiter，使该 GeminiAPI 的非流式调用（Invoke、InvokeInto、InvokeWithTools
+// 和会话的 SendMessage）受限速和用量统计约束；InvokeStream 等流式调用和 CountTokens 不经过 Limiter
+func (a *GeminiAPI) WithLimiter(l *Limiter) *GeminiAPI {
+	a.Limiter = l
+	return a
+}
+
+// WithBlobCache 挂载一个 BlobCache，使超过 MaxInlineBytes 而转存到 StagingBucket 的 BlobInput
+// 在重复调用间复用已上传的文件引用，而不是每次 Invoke 都重新上传同一份资源；内联发送的文件不进缓存
+func (a *GeminiAPI) WithBlobCache(c BlobCache) *GeminiAPI {
+	a.BlobCache = c
+	return a
+}
+
+// WithResponseSchema 让后续 Invoke 强制返回符合 schema 的 JSON
+// （设置 response_mime_type=application/json + response_schema）
+func (a *GeminiAPI) WithResponseSchema(schema *genai.Schema) *GeminiAPI {
+	a.ResponseSchema = schema
+	a.ResponseMIMEType = "application/json"
+	return a
+}
+
+// WithResponseStruct 和 WithResponseSchema 类似，但通过反射从 v 的 json tag 推导 schema，
+// v 通常传入目标结构体的零值指针，例如 &MyStruct{}
+func (a *GeminiAPI) WithResponseStruct(v any) *GeminiAPI {
+	return a.WithResponseSchema(structToSchema(v))
+}
+
+// resolvePart 把一个 Input 转换成 genai.Part；对于非 gs:// 的 BlobInput，如果配置了 BlobCache，
+// 会先按 cacheKey（由路径和文件元数据构成，不是内容哈希）查找是否已有可复用的文件引用，命中则跳过重新上传。
+// 只有转存后得到的 FileData 会写入缓存，内联发送的 Blob 每次都重新读取。
+func (a *GeminiAPI) resolvePart(ctx context.Context, input Input) (genai.Part, error) {
+	b, ok := input.(BlobInput)
+	if !ok || a.BlobCache == nil || strings.HasPrefix(b.Path, "gs://") {
+		return input.ToPart()
+	}
+
+	key, err := b.cacheKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if cached, hit, err := a.BlobCache.Get(ctx, key); err == nil && hit {
+		return genai.FileData{MIMEType: cached.MIMEType, FileURI: cached.FileURI}, nil
+	}
+
+	part, err := b.ToPart()
+	if err != nil {
+		return nil, err
+	}
+	if fd, ok := part.(genai.FileData); ok {
+		_ = a.BlobCache.Put(ctx, key, CachedBlob{FileURI: fd.FileURI, MIMEType: fd.MIMEType, ExpiresAt: time.Now().Add(BlobCacheTTL)})
+	}
+	return part, nil
+}
+
 // 定义一个接口，用于表示各种输入类型
 type Input interface {
 	ToPart() (genai.Part, error)
@@ -90,63 +194,151 @@ func (t TextInput) ToPart() (genai.Part, error) {
 	return genai.Text(t.Text), nil
 }
 
-// 实现其他模态输入
+// 实现其他模态输入，MIME 类型按内容/扩展名自动探测，PDF（application/pdf）和图片、音视频走相同路径
 type BlobInput struct {
 	Path string
+
+	// MaxInlineBytes 是内联发送给 Gemini 的最大字节数，0 表示不限制。
+	// 本地或 HTTP(S) 来源超过该阈值且设置了 StagingBucket 时会先上传到 StagingBucket，再以 FileData 引用传递；
+	// HTTP(S) 来源按响应的 Content-Length 判断，长度未知时直接读入内存内联发送。
+	MaxInlineBytes int64
+	// StagingBucket 是 MaxInlineBytes 触发时用于暂存大文件的 GCS bucket 名（不含 gs:// 前缀）
+	StagingBucket string
 }
 
 func (b BlobInput) ToPart() (genai.Part, error) {
+	ctx := context.Background()
+
 	if strings.HasPrefix(b.Path, "gs://") {
-		ctx := context.Background()
+		// GCS 路径本身就是引用，不需要下载/缓冲，直接读取元数据拿 MIME 类型
 		mimeType, err := getGCSFileMimeTypeFromMetadata(ctx, b.Path)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get GCS file mime type: %w", err)
 		}
-		// 如果是 GCS 路径，使用 genai.FileData
 		return genai.FileData{
 			MIMEType: mimeType,
 			FileURI:  b.Path,
 		}, nil
 	} else if strings.HasPrefix(b.Path, "http://") || strings.HasPrefix(b.Path, "https://") {
-		// 如果是 HTTP/HTTPS 路径，下载文件并转换为 Blob
-		tmpFile, err := downloadFile(b.Path)
+		return b.httpToPart(ctx)
+	}
+	return b.localToPart(ctx)
+}
+
+// httpToPart 流式下载 HTTP(S) 资源，超过 MaxInlineBytes 时转存到 StagingBucket 并返回 FileData 引用
+func (b BlobInput) httpToPart(ctx context.Context) (genai.Part, error) {
+	resp, err := http.Get(b.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to download file, status code: %d", resp.StatusCode)
+	}
+
+	if b.MaxInlineBytes > 0 && b.StagingBucket != "" && resp.ContentLength > b.MaxInlineBytes {
+		mimeType := resp.Header.Get("Content-Type")
+		if mimeType == "" {
+			mimeType = mime.TypeByExtension(filepath.Ext(b.Path))
+		}
+		uri, err := uploadToStagingBucket(ctx, b.StagingBucket, filepath.Base(b.Path), mimeType, resp.Body)
 		if err != nil {
-			return nil, fmt.Errorf("failed to download file: %w", err)
+			return nil, fmt.Errorf("failed to stage large file: %w", err)
 		}
-		defer os.Remove(tmpFile) // 确保函数退出时删除临时文件
-		data, err := os.ReadFile(tmpFile)
+		return genai.FileData{MIMEType: mimeType, FileURI: uri}, nil
+	}
+
+	// 较小的文件直接读入内存内联发送
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read downloaded file: %w", err)
+	}
+	mimeType := http.DetectContentType(data)
+	if mimeType == "application/octet-stream" { // fallback to extension
+		mimeType = mime.TypeByExtension(filepath.Ext(b.Path))
+	}
+	return genai.Blob{MIMEType: mimeType, Data: data}, nil
+}
+
+// localToPart 读取本地文件，超过 MaxInlineBytes 时转存到 StagingBucket 并返回 FileData 引用
+func (b BlobInput) localToPart(ctx context.Context) (genai.Part, error) {
+	info, err := os.Stat(b.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	if b.MaxInlineBytes > 0 && b.StagingBucket != "" && info.Size() > b.MaxInlineBytes {
+		f, err := os.Open(b.Path)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read downloaded file: %w", err)
+			return nil, fmt.Errorf("failed to open file: %w", err)
 		}
-		mimeType := http.DetectContentType(data)
-		if mimeType == "application/octet-stream" { // fallback to extension
-			mimeType = mime.TypeByExtension(filepath.Ext(b.Path))
+		defer f.Close()
+		mimeType := mime.TypeByExtension(filepath.Ext(b.Path))
+		uri, err := uploadToStagingBucket(ctx, b.StagingBucket, filepath.Base(b.Path), mimeType, f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stage large file: %w", err)
 		}
-		return genai.Blob{
-			MIMEType: mimeType,
-			Data:     data,
-		}, nil
-	} else {
-		// 如果是本地路径，使用 genai.Blob
-		data, err := os.ReadFile(b.Path)
+		return genai.FileData{MIMEType: mimeType, FileURI: uri}, nil
+	}
+
+	data, err := os.ReadFile(b.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	mimeType := http.DetectContentType(data)
+	if mimeType == "application/octet-stream" { // fallback to extension
+		mimeType = mime.TypeByExtension(filepath.Ext(b.Path))
+	}
+	return genai.Blob{MIMEType: mimeType, Data: data}, nil
+}
+
+// cacheKey 计算一个不需要完整读取内容的 BlobCache 键：本地文件用路径+大小+修改时间，
+// HTTP(S) 资源用 HEAD 请求拿到的 ETag/Last-Modified/Content-Length。这样即使 BlobCache
+// 命中了缓存也不需要先把整个文件读进内存，和 localToPart/httpToPart 的流式转存设计保持一致。
+// 只用于非 gs:// 来源，因为 gs:// 本身已经是可直接复用的引用。
+func (b BlobInput) cacheKey() (string, error) {
+	if strings.HasPrefix(b.Path, "http://") || strings.HasPrefix(b.Path, "https://") {
+		resp, err := http.Head(b.Path)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read file: %w", err)
+			return "", fmt.Errorf("failed to probe file: %w", err)
 		}
-		mimeType := http.DetectContentType(data)
-		if mimeType == "application/octet-stream" { // fallback to extension
-			mimeType = mime.TypeByExtension(filepath.Ext(b.Path))
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return "", fmt.Errorf("failed to probe file, status code: %d", resp.StatusCode)
 		}
-		return genai.Blob{
-			MIMEType: mimeType,
-			Data:     data,
-		}, nil
+		return fmt.Sprintf("http:%s:%d:%s:%s", b.Path, resp.ContentLength, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")), nil
 	}
+
+	info, err := os.Stat(b.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	return fmt.Sprintf("local:%s:%d:%d", b.Path, info.Size(), info.ModTime().UnixNano()), nil
 }
 
-func (a *GeminiAPI) Invoke(inputs ...Input) (string, error) {
-	ctx, cancelFn := context.WithTimeout(context.Background(), 180*time.Second)
-	defer cancelFn()
+// uploadToStagingBucket 把 r 流式写入 bucket/objectName，返回可供 FileData 引用的 gs:// URI
+func uploadToStagingBucket(ctx context.Context, bucket, objectName, mimeType string, r io.Reader) (string, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create GCS client: %w", err)
+	}
+	defer client.Close()
+
+	w := client.Bucket(bucket).Object(objectName).NewWriter(ctx)
+	w.ContentType = mimeType
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return "", fmt.Errorf("failed to stream upload: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to finalize upload: %w", err)
+	}
 
+	return fmt.Sprintf("gs://%s/%s", bucket, objectName), nil
+}
+
+// Invoke 调用 Gemini 生成内容。调用方通过 ctx 控制超时/取消，不再由本方法隐式设置 180s 超时。
+func (a *GeminiAPI) Invoke(ctx context.Context, inputs ...Input) (string, error) {
 	// 初始化客户端
 	err := a.InitClient(ctx)
 	if err != nil {
@@ -179,14 +371,16 @@ func (a *GeminiAPI) Invoke(inputs ...Input) (string, error) {
 	var parts []genai.Part
 	// 处理可变参数 inputs
 	for _, input := range inputs {
-		part, err := input.ToPart()
+		part, err := a.resolvePart(ctx, input)
 		if err != nil {
 			return "", err
 		}
 		parts = append(parts, part)
 	}
 
-	resp, err := a.retryableGenerateContent(ctx, client, parts...)
+	resp, err := a.retryableGenerateContent(ctx, estimateTokens(partsToPrompts(parts)), func(ctx context.Context) (*genai.GenerateContentResponse, error) {
+		return client.GenerateContent(ctx, parts...)
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to generate content: %w", err)
 	}
@@ -200,33 +394,88 @@ func (a *GeminiAPI) Invoke(inputs ...Input) (string, error) {
 	return resultStr, nil
 }
 
-func downloadFile(urlStr string) (string, error) {
-	parsedURL, err := url.Parse(urlStr)
-	if err != nil {
-		return "", fmt.Errorf("invalid URL: %w", err)
+// InvokeInto 和 Invoke 相同，但会把返回文本反序列化进 out。调用前应先用 WithResponseSchema/
+// WithResponseStruct 约束模型输出 JSON；如果返回的 JSON 无法反序列化进 out，会整体重试一次。
+func (a *GeminiAPI) InvokeInto(ctx context.Context, out any, inputs ...Input) (string, error) {
+	var text string
+	var err error
+	var unmarshalErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		text, err = a.Invoke(ctx, inputs...)
+		if err != nil {
+			return "", err
+		}
+		if unmarshalErr = json.Unmarshal([]byte(text), out); unmarshalErr == nil {
+			return text, nil
+		}
 	}
-	resp, err := http.Get(urlStr)
-	if err != nil {
+	return text, fmt.Errorf("model returned invalid JSON after retry: %w", unmarshalErr)
+}
+
+// invokeChat 和 Invoke 相同，但会把 history 之前的轮次重放给模型（通过 ChatSession），
+// 并在 systemInstruction 非空时把它设置到模型上，供 ChatSession 使用
+func (a *GeminiAPI) invokeChat(ctx context.Context, systemInstruction string, history []ChatMessage, inputs ...Input) (string, error) {
+	if err := a.InitClient(ctx); err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", fmt.Errorf("failed to download file, status code: %d", resp.StatusCode)
+	client := a.Client.GenerativeModel(a.ModelName)
+	client.SetTemperature(a.Temperature)
+	client.GenerationConfig.ResponseMIMEType = a.ResponseMIMEType
+	client.GenerationConfig.ResponseSchema = a.ResponseSchema
+	if systemInstruction != "" {
+		client.SystemInstruction = &genai.Content{Role: "user", Parts: []genai.Part{genai.Text(systemInstruction)}}
 	}
 
-	tmpFile, err := os.CreateTemp("", filepath.Base(parsedURL.Path)) // 使用原始文件名创建临时文件
-	if err != nil {
-		return "", err
+	var parts []genai.Part
+	for _, input := range inputs {
+		part, err := a.resolvePart(ctx, input)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
 	}
-	defer tmpFile.Close()
 
-	_, err = io.Copy(tmpFile, resp.Body)
+	cs := client.StartChat()
+	baseHistory := chatHistoryToGeminiContent(history)
+	var historyPrompts []string
+	for _, m := range history {
+		historyPrompts = append(historyPrompts, m.Parts...)
+	}
+	estimatedTokens := estimateTokens(partsToPrompts(parts)) + estimateTokens(historyPrompts)
+	resp, err := a.retryableGenerateContent(ctx, estimatedTokens, func(ctx context.Context) (*genai.GenerateContentResponse, error) {
+		// 每次重试都从原始历史重放，避免 ChatSession.SendMessage 在失败的尝试里
+		// 把同一轮用户输入重复追加进 cs.History
+		cs.History = append([]*genai.Content{}, baseHistory...)
+		return cs.SendMessage(ctx, parts...)
+	})
 	if err != nil {
-		os.Remove(tmpFile.Name())
-		return "", err
+		return "", fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("no response content found")
+	}
+
+	return fmt.Sprint(resp.Candidates[0].Content.Parts[0]), nil
+}
+
+// chatHistoryToGeminiContent 把 ChatSession 维护的历史转换成 ChatSession.History 需要的
+// []*genai.Content，"assistant"/"model" 统一映射成 Gemini 的 "model" 角色
+func chatHistoryToGeminiContent(history []ChatMessage) []*genai.Content {
+	out := make([]*genai.Content, 0, len(history))
+	for _, m := range history {
+		role := m.Role
+		if role == "assistant" {
+			role = "model"
+		}
+		parts := make([]genai.Part, 0, len(m.Parts))
+		for _, p := range m.Parts {
+			parts = append(parts, genai.Text(p))
+		}
+		out = append(out, &genai.Content{Role: role, Parts: parts})
 	}
-	return tmpFile.Name(), nil
+	return out
 }
 
 func getGCSFileMimeTypeFromMetadata(ctx context.Context, gcsPath string) (string, error) {
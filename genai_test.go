@@ -1,14 +1,16 @@
 package genai
 
 import (
+	"context"
 	"testing"
 	"time"
 )
 
 func TestGeminiInvokeText(t *testing.T) {
+	ctx := context.Background()
 	gemini := NewGeminiAPI("us-central1", "speedy-victory-336109", "gemini-1.5-flash-002", 1, 3, 1*time.Second)
 
-	resp, err := gemini.Invoke(
+	resp, err := gemini.Invoke(ctx,
 		TextInput{Text: "who are you?"},
 	)
 	if err != nil {
@@ -17,7 +19,7 @@ func TestGeminiInvokeText(t *testing.T) {
 	t.Log(resp)
 
 	// 测试多文本输入
-	resp2, err := gemini.Invoke(
+	resp2, err := gemini.Invoke(ctx,
 		TextInput{Text: "你好"},
 		TextInput{Text: "请回答今天天气如何？"},
 	)
@@ -28,9 +30,10 @@ func TestGeminiInvokeText(t *testing.T) {
 }
 
 func TestGeminiInvokeImage(t *testing.T) {
+	ctx := context.Background()
 	gemini := NewGeminiAPI("us-central1", "speedy-victory-336109", "gemini-1.5-flash-002", 1, 3, 1*time.Second)
 
-	resp, err := gemini.Invoke(
+	resp, err := gemini.Invoke(ctx,
 		TextInput{Text: "一共有几张图片?"},
 		TextInput{Text: "描述这个图片内容"},
 		TextInput{Text: "输出语言为中文"},
@@ -45,9 +48,10 @@ func TestGeminiInvokeImage(t *testing.T) {
 }
 
 func TestGeminiInvokeVideoGCS(t *testing.T) {
+	ctx := context.Background()
 	gemini := NewGeminiAPI("us-central1", "speedy-victory-336109", "gemini-1.5-flash-002", 1, 3, 1*time.Second)
 
-	resp, err := gemini.Invoke(
+	resp, err := gemini.Invoke(ctx,
 		TextInput{Text: "描述视频内容"},
 		TextInput{Text: "用中文输出"},
 		BlobInput{Path: "gs://pwm-lowa/videos/f4f4781e-6cd7-11ee-aae4-eedee28ea4dd.mp4"},
@@ -59,9 +63,10 @@ func TestGeminiInvokeVideoGCS(t *testing.T) {
 }
 
 func TestGeminiInvokeVideoPublic(t *testing.T) {
+	ctx := context.Background()
 	gemini := NewGeminiAPI("us-central1", "speedy-victory-336109", "gemini-1.5-flash-002", 1, 3, 1*time.Second)
 
-	resp, err := gemini.Invoke(
+	resp, err := gemini.Invoke(ctx,
 		TextInput{Text: "描述视频内容"},
 		TextInput{Text: "用中文输出"},
 		BlobInput{Path: "https://storage.googleapis.com/pwm-lowa/videos/f4f4781e-6cd7-11ee-aae4-eedee28ea4dd.mp4"},
@@ -73,9 +78,10 @@ func TestGeminiInvokeVideoPublic(t *testing.T) {
 }
 
 func TestGeminiInvokeAudio(t *testing.T) {
+	ctx := context.Background()
 	gemini := NewGeminiAPI("us-central1", "speedy-victory-336109", "gemini-1.5-flash-002", 1, 3, 1*time.Second)
 
-	resp, err := gemini.Invoke(
+	resp, err := gemini.Invoke(ctx,
 		TextInput{Text: "提取音频脚本"},
 		TextInput{Text: "用中文输出"},
 		BlobInput{Path: "testdata/test1.mp3"},
@@ -87,8 +93,9 @@ func TestGeminiInvokeAudio(t *testing.T) {
 }
 
 func TestGeminiIvokeVideo(t *testing.T) {
+	ctx := context.Background()
 	gemini := NewGeminiAPI("us-central1", "speedy-victory-336109", "gemini-1.5-flash-002", 1, 3, 1*time.Second)
-	resp, err := gemini.Invoke(
+	resp, err := gemini.Invoke(ctx,
 		TextInput{Text: "分别提取下列视频的脚本"},
 		TextInput{Text: "用中文输出"},
 		BlobInput{Path: "testdata/test1.mp4"},
@@ -101,9 +108,10 @@ func TestGeminiIvokeVideo(t *testing.T) {
 }
 
 func TestClaudeInvokeText(t *testing.T) {
+	ctx := context.Background()
 	claude := NewClaudeAPI("us-east5", "speedy-victory-336109", "claude-3-5-sonnet@20240620", 1, 3, 1)
 	text_prompts := []string{"who are you?"}
-	resp, err := claude.Invoke(text_prompts, []string{})
+	resp, err := claude.Invoke(ctx, text_prompts, []string{})
 	if err != nil {
 		t.Error(err)
 	}
@@ -111,6 +119,7 @@ func TestClaudeInvokeText(t *testing.T) {
 }
 
 func TestClaudeInvokeImage(t *testing.T) {
+	ctx := context.Background()
 	claude := NewClaudeAPI("us-east5", "speedy-victory-336109", "claude-3-5-sonnet@20240620", 1, 3, 1)
 	image_paths := []string{
 		"testdata/test1.jpeg",
@@ -122,7 +131,7 @@ func TestClaudeInvokeImage(t *testing.T) {
 		"描述图片内容",
 		"输出语言为中文",
 	}
-	resp, err := claude.Invoke(text_prompts, image_paths)
+	resp, err := claude.Invoke(ctx, text_prompts, image_paths)
 	if err != nil {
 		t.Error(err)
 	}
@@ -0,0 +1,68 @@
+package genai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tidwall/buntdb"
+)
+
+// BuntDBHistoryStore 把会话历史持久化到本地嵌入式 BuntDB，适合单机部署需要重启后恢复会话的场景
+type BuntDBHistoryStore struct {
+	DB *buntdb.DB
+}
+
+// NewBuntDBHistoryStore 打开（或创建）path 指向的 BuntDB 文件作为 HistoryStore
+func NewBuntDBHistoryStore(path string) (*BuntDBHistoryStore, error) {
+	db, err := buntdb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open buntdb: %w", err)
+	}
+	return &BuntDBHistoryStore{DB: db}, nil
+}
+
+func (s *BuntDBHistoryStore) Load(ctx context.Context, sessionID string) ([]ChatMessage, error) {
+	var raw string
+	err := s.DB.View(func(tx *buntdb.Tx) error {
+		val, err := tx.Get(sessionID)
+		if err != nil {
+			return err
+		}
+		raw = val
+		return nil
+	})
+	if err == buntdb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history from buntdb: %w", err)
+	}
+
+	var history []ChatMessage
+	if err := json.Unmarshal([]byte(raw), &history); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal history: %w", err)
+	}
+	return history, nil
+}
+
+func (s *BuntDBHistoryStore) Save(ctx context.Context, sessionID string, history []ChatMessage) error {
+	raw, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+	return s.DB.Update(func(tx *buntdb.Tx) error {
+		_, _, err := tx.Set(sessionID, string(raw), nil)
+		return err
+	})
+}
+
+func (s *BuntDBHistoryStore) Clear(ctx context.Context, sessionID string) error {
+	return s.DB.Update(func(tx *buntdb.Tx) error {
+		_, err := tx.Delete(sessionID)
+		if err == buntdb.ErrNotFound {
+			return nil
+		}
+		return err
+	})
+}
@@ -0,0 +1,59 @@
+package genai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisHistoryStore 把会话历史持久化到 Redis，适合多实例部署共享会话状态
+type RedisHistoryStore struct {
+	Client *redis.Client
+	Prefix string        // key 前缀，默认 "genai:chat:"
+	TTL    time.Duration // 0 表示不过期
+}
+
+// NewRedisHistoryStore 创建一个 Redis 支持的 HistoryStore
+func NewRedisHistoryStore(client *redis.Client, ttl time.Duration) *RedisHistoryStore {
+	return &RedisHistoryStore{Client: client, Prefix: "genai:chat:", TTL: ttl}
+}
+
+func (s *RedisHistoryStore) key(sessionID string) string {
+	return s.Prefix + sessionID
+}
+
+func (s *RedisHistoryStore) Load(ctx context.Context, sessionID string) ([]ChatMessage, error) {
+	raw, err := s.Client.Get(ctx, s.key(sessionID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history from redis: %w", err)
+	}
+	var history []ChatMessage
+	if err := json.Unmarshal(raw, &history); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal history: %w", err)
+	}
+	return history, nil
+}
+
+func (s *RedisHistoryStore) Save(ctx context.Context, sessionID string, history []ChatMessage) error {
+	raw, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+	if err := s.Client.Set(ctx, s.key(sessionID), raw, s.TTL).Err(); err != nil {
+		return fmt.Errorf("failed to save history to redis: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisHistoryStore) Clear(ctx context.Context, sessionID string) error {
+	if err := s.Client.Del(ctx, s.key(sessionID)).Err(); err != nil {
+		return fmt.Errorf("failed to clear history in redis: %w", err)
+	}
+	return nil
+}
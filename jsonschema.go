@@ -0,0 +1,75 @@
+package genai
+
+import (
+	"reflect"
+	"strings"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// structToSchema 通过反射把一个 Go struct（或其指针）转换成 genai.Schema，字段名优先取 json tag，
+// 带 omitempty 的字段视为可选。只覆盖 WithResponseStruct 常见的标量/切片/嵌套 struct 场景。
+func structToSchema(v any) *genai.Schema {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return typeToSchema(t)
+}
+
+func typeToSchema(t reflect.Type) *genai.Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeToSchema(t.Elem())
+	case reflect.Struct:
+		properties := map[string]*genai.Schema{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, omitempty := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+			properties[name] = typeToSchema(field.Type)
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+		return &genai.Schema{Type: genai.TypeObject, Properties: properties, Required: required}
+	case reflect.Slice, reflect.Array:
+		return &genai.Schema{Type: genai.TypeArray, Items: typeToSchema(t.Elem())}
+	case reflect.String:
+		return &genai.Schema{Type: genai.TypeString}
+	case reflect.Bool:
+		return &genai.Schema{Type: genai.TypeBoolean}
+	case reflect.Float32, reflect.Float64:
+		return &genai.Schema{Type: genai.TypeNumber}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &genai.Schema{Type: genai.TypeInteger}
+	default:
+		return &genai.Schema{Type: genai.TypeString}
+	}
+}
+
+// jsonFieldName 返回一个结构体字段在 json 序列化中使用的名字，以及它是否标记了 omitempty
+func jsonFieldName(field reflect.StructField) (name string, omitempty bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty
+}
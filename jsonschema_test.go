@@ -0,0 +1,46 @@
+package genai
+
+import (
+	"testing"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+func TestStructToSchema(t *testing.T) {
+	type Inner struct {
+		Count int `json:"count"`
+	}
+	type Sample struct {
+		Name    string   `json:"name"`
+		Tags    []string `json:"tags,omitempty"`
+		Details Inner    `json:"details"`
+	}
+
+	schema := structToSchema(&Sample{})
+	if schema.Type != genai.TypeObject {
+		t.Fatalf("expected object schema, got %v", schema.Type)
+	}
+	if schema.Properties["name"].Type != genai.TypeString {
+		t.Errorf("expected name to be a string schema")
+	}
+	if schema.Properties["tags"].Type != genai.TypeArray {
+		t.Errorf("expected tags to be an array schema")
+	}
+	if schema.Properties["details"].Type != genai.TypeObject {
+		t.Errorf("expected details to be an object schema")
+	}
+	if schema.Properties["details"].Properties["count"].Type != genai.TypeInteger {
+		t.Errorf("expected nested count to be an integer schema")
+	}
+
+	required := map[string]bool{}
+	for _, r := range schema.Required {
+		required[r] = true
+	}
+	if !required["name"] || !required["details"] {
+		t.Errorf("expected name and details to be required, got %v", schema.Required)
+	}
+	if required["tags"] {
+		t.Errorf("expected tags (omitempty) to not be required")
+	}
+}
@@ -0,0 +1,210 @@
+package genai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PriceTable 维护每个模型每百万 token 的美元价格，用于估算花费
+type PriceTable map[string]struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+// limiterKey 标识一个独立计量的 (ProjectID, Model) 组合
+type limiterKey struct {
+	ProjectID string
+	Model     string
+}
+
+// bucket 是一个简单的 token-bucket，用于请求数或 token 数的限速
+type bucket struct {
+	mu         sync.Mutex
+	capacity   float64
+	tokens     float64
+	refillRate float64 // 每秒补充的 token 数
+	lastRefill time.Time
+}
+
+func newBucket(capacity float64, refillPerSecond float64) *bucket {
+	return &bucket{capacity: capacity, tokens: capacity, refillRate: refillPerSecond, lastRefill: time.Now()}
+}
+
+func (b *bucket) refill() {
+	now := time.Now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.tokens = minFloat(b.capacity, b.tokens+elapsed*b.refillRate)
+	b.lastRefill = now
+}
+
+// take 阻塞直至有 n 个 token 可用或 ctx 取消。refillRate<=0 表示不限速（RequestsPerMinute/
+// TokensPerMinute 配置为 0），此时直接放行，否则下面的 wait 计算会除以零。
+func (b *bucket) take(ctx context.Context, n float64) error {
+	for {
+		b.mu.Lock()
+		if b.refillRate <= 0 {
+			b.mu.Unlock()
+			return nil
+		}
+		b.refill()
+		if b.tokens >= n {
+			b.tokens -= n
+			b.mu.Unlock()
+			return nil
+		}
+		deficit := n - b.tokens
+		wait := time.Duration(deficit/b.refillRate*1000) * time.Millisecond
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// slowDown 降低补充速率，用于收到 429 后的自适应退避
+func (b *bucket) slowDown(factor float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillRate *= factor
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ModelStats 记录单个模型的累计用量
+type ModelStats struct {
+	Requests       int64
+	PromptTokens   int64
+	OutputTokens   int64
+	CostUSD        float64
+	LatencySeconds float64
+}
+
+// Stats 是 Limiter.Stats() 返回的快照
+type Stats map[string]ModelStats // key 为 "ProjectID/Model"
+
+// Limiter 在 (ProjectID, Model) 粒度上做请求数/token 数限速，并累计 token 用量和花费
+type Limiter struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+	Prices            PriceTable
+
+	mu        sync.Mutex
+	reqBkts   map[limiterKey]*bucket
+	tokBkts   map[limiterKey]*bucket
+	stats     map[limiterKey]*ModelStats
+	collector *PromCollector
+}
+
+// NewLimiter 创建一个按 RPM/TPM 限速的 Limiter
+func NewLimiter(requestsPerMinute, tokensPerMinute int, prices PriceTable) *Limiter {
+	return &Limiter{
+		RequestsPerMinute: requestsPerMinute,
+		TokensPerMinute:   tokensPerMinute,
+		Prices:            prices,
+		reqBkts:           make(map[limiterKey]*bucket),
+		tokBkts:           make(map[limiterKey]*bucket),
+		stats:             make(map[limiterKey]*ModelStats),
+	}
+}
+
+// WithCollector 挂载一个 Prometheus collector，使限速统计同时推送到 Prometheus
+func (l *Limiter) WithCollector(c *PromCollector) *Limiter {
+	l.collector = c
+	return l
+}
+
+func (l *Limiter) bucketsFor(key limiterKey) (*bucket, *bucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	req, ok := l.reqBkts[key]
+	if !ok {
+		req = newBucket(float64(l.RequestsPerMinute), float64(l.RequestsPerMinute)/60)
+		l.reqBkts[key] = req
+	}
+	tok, ok := l.tokBkts[key]
+	if !ok {
+		tok = newBucket(float64(l.TokensPerMinute), float64(l.TokensPerMinute)/60)
+		l.tokBkts[key] = tok
+	}
+	return req, tok
+}
+
+// Acquire 在发起一次调用前阻塞，直至该 (projectID, model) 还有可用的请求和 token 配额
+func (l *Limiter) Acquire(ctx context.Context, projectID, model string, estimatedTokens int) error {
+	key := limiterKey{ProjectID: projectID, Model: model}
+	req, tok := l.bucketsFor(key)
+	if err := req.take(ctx, 1); err != nil {
+		return fmt.Errorf("rate limit wait cancelled: %w", err)
+	}
+	if estimatedTokens > 0 {
+		if err := tok.take(ctx, float64(estimatedTokens)); err != nil {
+			return fmt.Errorf("token budget wait cancelled: %w", err)
+		}
+	}
+	return nil
+}
+
+// OnThrottled 在收到 429 后调用，让 token 桶补充得更慢，从而自适应地退避
+func (l *Limiter) OnThrottled(projectID, model string) {
+	key := limiterKey{ProjectID: projectID, Model: model}
+	l.mu.Lock()
+	req, reqOk := l.reqBkts[key]
+	tok, tokOk := l.tokBkts[key]
+	l.mu.Unlock()
+	if reqOk {
+		req.slowDown(0.5)
+	}
+	if tokOk {
+		tok.slowDown(0.5)
+	}
+}
+
+// Record 记录一次调用的实际用量，累加 token 计数并按 PriceTable 估算花费
+func (l *Limiter) Record(projectID, model string, promptTokens, outputTokens int, latency time.Duration) {
+	key := limiterKey{ProjectID: projectID, Model: model}
+
+	l.mu.Lock()
+	s, ok := l.stats[key]
+	if !ok {
+		s = &ModelStats{}
+		l.stats[key] = s
+	}
+	s.Requests++
+	s.PromptTokens += int64(promptTokens)
+	s.OutputTokens += int64(outputTokens)
+	s.LatencySeconds += latency.Seconds()
+	var cost float64
+	if price, ok := l.Prices[model]; ok {
+		cost = float64(promptTokens)/1_000_000*price.InputPerMillion +
+			float64(outputTokens)/1_000_000*price.OutputPerMillion
+		s.CostUSD += cost
+	}
+	l.mu.Unlock()
+
+	if l.collector != nil {
+		l.collector.Observe(model, promptTokens, outputTokens, cost, latency)
+	}
+}
+
+// Stats 返回所有 (ProjectID, Model) 的累计用量快照
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make(Stats, len(l.stats))
+	for key, s := range l.stats {
+		out[key.ProjectID+"/"+key.Model] = *s
+	}
+	return out
+}
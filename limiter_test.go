@@ -0,0 +1,29 @@
+package genai
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBucketTakeUnlimited(t *testing.T) {
+	b := newBucket(0, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := b.take(ctx, 1000); err != nil {
+		t.Fatalf("take on an unlimited bucket should return immediately, got: %v", err)
+	}
+}
+
+func TestBucketTakeWithinCapacity(t *testing.T) {
+	b := newBucket(10, 10)
+	ctx := context.Background()
+
+	if err := b.take(ctx, 5); err != nil {
+		t.Fatalf("take within capacity should succeed, got: %v", err)
+	}
+	if b.tokens != 5 {
+		t.Errorf("expected 5 tokens remaining, got %v", b.tokens)
+	}
+}
@@ -0,0 +1,54 @@
+package genai
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PromCollector 把 Limiter 的用量统计以标准 Prometheus 指标的形式暴露出去
+type PromCollector struct {
+	requestsTotal *prometheus.CounterVec
+	tokensTotal   *prometheus.CounterVec
+	costTotal     *prometheus.CounterVec
+	latency       *prometheus.HistogramVec
+}
+
+// NewPromCollector 创建并向 reg 注册 genai_* 系列指标。reg 为 nil 时使用默认 Registerer。
+func NewPromCollector(reg prometheus.Registerer) *PromCollector {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	c := &PromCollector{
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "genai_requests_total",
+			Help: "Total number of genai API calls, labeled by model.",
+		}, []string{"model"}),
+		tokensTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "genai_tokens_total",
+			Help: "Total number of prompt/output tokens, labeled by model and kind.",
+		}, []string{"model", "kind"}),
+		costTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "genai_cost_usd_total",
+			Help: "Estimated cumulative cost in USD, labeled by model.",
+		}, []string{"model"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "genai_latency_seconds",
+			Help:    "Latency of genai API calls, labeled by model.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"model"}),
+	}
+
+	reg.MustRegister(c.requestsTotal, c.tokensTotal, c.costTotal, c.latency)
+	return c
+}
+
+// Observe 记录单次调用的增量用量（非累计值）
+func (c *PromCollector) Observe(model string, promptTokens, outputTokens int, costUSD float64, latency time.Duration) {
+	c.requestsTotal.WithLabelValues(model).Inc()
+	c.tokensTotal.WithLabelValues(model, "prompt").Add(float64(promptTokens))
+	c.tokensTotal.WithLabelValues(model, "output").Add(float64(outputTokens))
+	c.costTotal.WithLabelValues(model).Add(costUSD)
+	c.latency.WithLabelValues(model).Observe(latency.Seconds())
+}
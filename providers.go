@@ -0,0 +1,510 @@
+package genai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// httpJSONCall 发起一次 POST JSON 请求并在配额/瞬时错误上按 full jitter 退避重试，
+// 是 OpenAIAPI/AnthropicAPI/OllamaAPI 共用的传输层，避免各自重复实现重试循环。
+func httpJSONCall(ctx context.Context, client *http.Client, maxRetries int, retryDelay time.Duration, buildReq func() (*http.Request, error)) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		req, err := buildReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("http request failed: %w", err)
+		} else {
+			body, readErr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if readErr != nil {
+				lastErr = fmt.Errorf("failed to read response body: %w", readErr)
+			} else if resp.StatusCode != http.StatusOK {
+				lastErr = fmt.Errorf("unexpected status code: %d, response body: %s", resp.StatusCode, string(body))
+			} else {
+				return body, nil
+			}
+		}
+
+		apiErr := parseAPIError(lastErr, "")
+		if !apiErr.IsQuotaExceeded() && !apiErr.IsTransient() {
+			return nil, apiErr
+		}
+		if attempt == maxRetries {
+			return nil, fmt.Errorf("failed after %d retries: %w", maxRetries, apiErr)
+		}
+		if sleepErr := sleepWithContext(ctx, fullJitterBackoff(retryDelay, attempt)); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+	return nil, lastErr
+}
+
+// partsToOpenAIContent 把一组 Input 转成 OpenAI chat completions 的 content 数组；
+// 文本部分映射为 text block，图片/文件部分映射为 image_url block（内联文件走 data URL）。
+func partsToOpenAIContent(inputs []Input) ([]map[string]any, error) {
+	var content []map[string]any
+	for _, input := range inputs {
+		part, err := input.ToPart()
+		if err != nil {
+			return nil, err
+		}
+		switch v := part.(type) {
+		case genai.Text:
+			content = append(content, map[string]any{"type": "text", "text": string(v)})
+		case genai.Blob:
+			dataURL := fmt.Sprintf("data:%s;base64,%s", v.MIMEType, base64.StdEncoding.EncodeToString(v.Data))
+			content = append(content, map[string]any{"type": "image_url", "image_url": map[string]any{"url": dataURL}})
+		case genai.FileData:
+			content = append(content, map[string]any{"type": "image_url", "image_url": map[string]any{"url": v.FileURI}})
+		default:
+			return nil, fmt.Errorf("unsupported input part type %T", part)
+		}
+	}
+	return content, nil
+}
+
+// OpenAIAPI 直连 OpenAI（而非经由 Vertex）的 Chat Completions 接口
+type OpenAIAPI struct {
+	APIKey      string
+	BaseURL     string // 默认 https://api.openai.com/v1
+	Model       string
+	Temperature float32
+	MaxRetries  int
+	RetryDelay  time.Duration
+	httpClient  *http.Client
+}
+
+// NewOpenAIAPI 创建 API 实例
+func NewOpenAIAPI(apiKey, model string, temperature float32, maxRetries int, retryDelay time.Duration) *OpenAIAPI {
+	return &OpenAIAPI{
+		APIKey:      apiKey,
+		BaseURL:     "https://api.openai.com/v1",
+		Model:       model,
+		Temperature: temperature,
+		MaxRetries:  maxRetries,
+		RetryDelay:  retryDelay,
+		httpClient:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+type openAIChatRequest struct {
+	Model       string           `json:"model"`
+	Messages    []map[string]any `json:"messages"`
+	Temperature float32          `json:"temperature"`
+	Stream      bool             `json:"stream,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// Invoke 调用 OpenAI 生成内容。调用方通过 ctx 控制超时/取消；每次 HTTP 请求另受 httpClient 的 60s 超时限制。
+func (a *OpenAIAPI) Invoke(ctx context.Context, inputs ...Input) (string, error) {
+	content, err := partsToOpenAIContent(inputs)
+	if err != nil {
+		return "", err
+	}
+
+	request := openAIChatRequest{
+		Model:       a.Model,
+		Temperature: a.Temperature,
+		Messages:    []map[string]any{{"role": "user", "content": content}},
+	}
+
+	body, err := a.call(ctx, "/chat/completions", request)
+	if err != nil {
+		return "", fmt.Errorf("failed to call openai model: %w", err)
+	}
+
+	var resp openAIChatResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response choices found")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// InvokeStream 以流式方式调用 OpenAI，增量结果通过 channel 返回
+func (a *OpenAIAPI) InvokeStream(ctx context.Context, inputs ...Input) (<-chan StreamChunk, error) {
+	content, err := partsToOpenAIContent(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	request := openAIChatRequest{
+		Model:       a.Model,
+		Temperature: a.Temperature,
+		Messages:    []map[string]any{{"role": "user", "content": content}},
+		Stream:      true,
+	}
+	payloadBytes, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.BaseURL+"/chat/completions", bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+a.APIKey)
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "[DONE]" {
+				return
+			}
+
+			var event struct {
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+					FinishReason string `json:"finish_reason"`
+				} `json:"choices"`
+			}
+			if err := json.Unmarshal([]byte(data), &event); err != nil || len(event.Choices) == 0 {
+				continue
+			}
+
+			chunk := StreamChunk{Text: event.Choices[0].Delta.Content, FinishReason: event.Choices[0].FinishReason}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (a *OpenAIAPI) call(ctx context.Context, path string, request openAIChatRequest) ([]byte, error) {
+	return httpJSONCall(ctx, a.httpClient, a.MaxRetries, a.RetryDelay, func() (*http.Request, error) {
+		payloadBytes, err := json.Marshal(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.BaseURL+path, bytes.NewReader(payloadBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+a.APIKey)
+		req.Header.Set("Content-Type", contentType)
+		return req, nil
+	})
+}
+
+// AsProvider 把 OpenAIAPI 包装成满足 Provider 接口的实现
+func (a *OpenAIAPI) AsProvider() Provider { return openAILLM{api: a} }
+
+type openAILLM struct{ api *OpenAIAPI }
+
+func (o openAILLM) Invoke(ctx context.Context, inputs ...Input) (Response, error) {
+	text, err := o.api.Invoke(ctx, inputs...)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{Text: text}, nil
+}
+
+func (o openAILLM) InvokeStream(ctx context.Context, inputs ...Input) (<-chan StreamChunk, error) {
+	return o.api.InvokeStream(ctx, inputs...)
+}
+
+func (o openAILLM) CountTokens(ctx context.Context, inputs ...Input) (int, error) {
+	return estimateTokens(inputsToPrompts(inputs)), nil
+}
+
+func (o openAILLM) Models() []string {
+	return []string{o.api.Model}
+}
+
+// AnthropicAPI 直连 Anthropic（而非经由 Vertex）的 Messages API，复用 ClaudeAPI 的消息/请求
+// 构造逻辑，只是换了鉴权方式（x-api-key）和目标地址
+type AnthropicAPI struct {
+	APIKey      string
+	BaseURL     string // 默认 https://api.anthropic.com/v1
+	Model       string
+	Temperature float32
+	MaxRetries  int
+	RetryDelay  time.Duration
+	httpClient  *http.Client
+}
+
+// NewAnthropicAPI 创建 API 实例
+func NewAnthropicAPI(apiKey, model string, temperature float32, maxRetries int, retryDelay time.Duration) *AnthropicAPI {
+	return &AnthropicAPI{
+		APIKey:      apiKey,
+		BaseURL:     "https://api.anthropic.com/v1",
+		Model:       model,
+		Temperature: temperature,
+		MaxRetries:  maxRetries,
+		RetryDelay:  retryDelay,
+		httpClient:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Invoke 调用 Anthropic 模型。调用方通过 ctx 控制超时/取消；每次 HTTP 请求另受 httpClient 的 60s 超时限制。
+func (a *AnthropicAPI) Invoke(ctx context.Context, prompts []string, img_paths []string) (string, error) {
+	messages, err := buildClaudeMessages(prompts, img_paths)
+	if err != nil {
+		return "", err
+	}
+	request, err := newClaudeRequest(a.Temperature, messages)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+	request.Model = a.Model
+
+	body, err := httpJSONCall(ctx, a.httpClient, a.MaxRetries, a.RetryDelay, func() (*http.Request, error) {
+		payloadBytes, err := json.Marshal(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.BaseURL+"/messages", bytes.NewReader(payloadBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("x-api-key", a.APIKey)
+		req.Header.Set("anthropic-version", anthropicVersion)
+		req.Header.Set("Content-Type", contentType)
+		return req, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to call anthropic model: %w", err)
+	}
+
+	var resp ClaudeResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return parseClaudeResponseContent(resp)
+}
+
+// AsProvider 把 AnthropicAPI 包装成满足 Provider 接口的实现
+func (a *AnthropicAPI) AsProvider() Provider { return anthropicLLM{api: a} }
+
+type anthropicLLM struct{ api *AnthropicAPI }
+
+func (c anthropicLLM) Invoke(ctx context.Context, inputs ...Input) (Response, error) {
+	text, err := c.api.Invoke(ctx, inputsToPrompts(inputs), nil)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{Text: text}, nil
+}
+
+func (c anthropicLLM) InvokeStream(ctx context.Context, inputs ...Input) (<-chan StreamChunk, error) {
+	text, err := c.api.Invoke(ctx, inputsToPrompts(inputs), nil)
+	out := make(chan StreamChunk, 1)
+	if err != nil {
+		out <- StreamChunk{Err: err}
+	} else {
+		out <- StreamChunk{Text: text, FinishReason: "stop"}
+	}
+	close(out)
+	return out, nil
+}
+
+func (c anthropicLLM) CountTokens(ctx context.Context, inputs ...Input) (int, error) {
+	return estimateTokens(inputsToPrompts(inputs)), nil
+}
+
+func (c anthropicLLM) Models() []string {
+	return []string{c.api.Model}
+}
+
+// OllamaAPI 调用本地 Ollama 服务（默认 http://localhost:11434）运行的模型
+type OllamaAPI struct {
+	BaseURL     string // 默认 http://localhost:11434
+	Model       string
+	Temperature float32
+	MaxRetries  int
+	RetryDelay  time.Duration
+	httpClient  *http.Client
+}
+
+// NewOllamaAPI 创建 API 实例
+func NewOllamaAPI(model string, temperature float32, maxRetries int, retryDelay time.Duration) *OllamaAPI {
+	return &OllamaAPI{
+		BaseURL:     "http://localhost:11434",
+		Model:       model,
+		Temperature: temperature,
+		MaxRetries:  maxRetries,
+		RetryDelay:  retryDelay,
+		httpClient:  &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+type ollamaChatRequest struct {
+	Model    string           `json:"model"`
+	Messages []map[string]any `json:"messages"`
+	Stream   bool             `json:"stream"`
+	Options  map[string]any   `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done bool `json:"done"`
+}
+
+// Invoke 调用本地 Ollama 模型生成内容，只支持文本输入
+func (a *OllamaAPI) Invoke(ctx context.Context, inputs ...Input) (string, error) {
+	prompts := inputsToPrompts(inputs)
+
+	request := ollamaChatRequest{
+		Model:    a.Model,
+		Messages: []map[string]any{{"role": "user", "content": strings.Join(prompts, "\n")}},
+		Options:  map[string]any{"temperature": a.Temperature},
+	}
+
+	body, err := httpJSONCall(ctx, a.httpClient, a.MaxRetries, a.RetryDelay, func() (*http.Request, error) {
+		payloadBytes, err := json.Marshal(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		return http.NewRequestWithContext(ctx, http.MethodPost, a.BaseURL+"/api/chat", bytes.NewReader(payloadBytes))
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to call ollama model: %w", err)
+	}
+
+	var resp ollamaChatResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return resp.Message.Content, nil
+}
+
+// InvokeStream 以流式方式调用本地 Ollama 模型，增量结果通过 channel 返回
+func (a *OllamaAPI) InvokeStream(ctx context.Context, inputs ...Input) (<-chan StreamChunk, error) {
+	prompts := inputsToPrompts(inputs)
+	request := ollamaChatRequest{
+		Model:    a.Model,
+		Messages: []map[string]any{{"role": "user", "content": strings.Join(prompts, "\n")}},
+		Stream:   true,
+		Options:  map[string]any{"temperature": a.Temperature},
+	}
+	payloadBytes, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.BaseURL+"/api/chat", bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		defer resp.Body.Close()
+
+		// Ollama 的 /api/chat 流式响应是每行一个 JSON 对象，不是 SSE
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var event ollamaChatResponse
+			if err := json.Unmarshal(line, &event); err != nil {
+				continue
+			}
+			chunk := StreamChunk{Text: event.Message.Content}
+			if event.Done {
+				chunk.FinishReason = "stop"
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// AsProvider 把 OllamaAPI 包装成满足 Provider 接口的实现
+func (a *OllamaAPI) AsProvider() Provider { return ollamaLLM{api: a} }
+
+type ollamaLLM struct{ api *OllamaAPI }
+
+func (o ollamaLLM) Invoke(ctx context.Context, inputs ...Input) (Response, error) {
+	text, err := o.api.Invoke(ctx, inputs...)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{Text: text}, nil
+}
+
+func (o ollamaLLM) InvokeStream(ctx context.Context, inputs ...Input) (<-chan StreamChunk, error) {
+	return o.api.InvokeStream(ctx, inputs...)
+}
+
+func (o ollamaLLM) CountTokens(ctx context.Context, inputs ...Input) (int, error) {
+	return estimateTokens(inputsToPrompts(inputs)), nil
+}
+
+func (o ollamaLLM) Models() []string {
+	return []string{o.api.Model}
+}
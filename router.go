@@ -0,0 +1,335 @@
+package genai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// Response 是 LLM.Invoke 的统一返回结构
+type Response struct {
+	Text         string
+	PromptTokens int
+	OutputTokens int
+}
+
+// LLM 是各 provider（GeminiAPI、ClaudeAPI 等）经 AsProvider 适配后共同实现的统一接口，
+// 使调用方可以不关心具体 provider 就完成调用与流式调用；token 计数见 Provider
+type LLM interface {
+	Invoke(ctx context.Context, inputs ...Input) (Response, error)
+	InvokeStream(ctx context.Context, inputs ...Input) (<-chan StreamChunk, error)
+}
+
+// Provider 在 LLM 之上补充了 token 计数和可用模型列表，是 Router 管理的各家
+// （Vertex Gemini/Claude、直连 OpenAI/Anthropic、本地 Ollama）后端共同实现的接口
+type Provider interface {
+	LLM
+	CountTokens(ctx context.Context, inputs ...Input) (int, error)
+	Models() []string
+}
+
+// geminiLLM 把 *GeminiAPI 适配成 Provider 接口
+type geminiLLM struct{ api *GeminiAPI }
+
+// AsProvider 把 GeminiAPI 包装成满足 Provider 接口的实现
+func (a *GeminiAPI) AsProvider() Provider { return geminiLLM{api: a} }
+
+func (g geminiLLM) Invoke(ctx context.Context, inputs ...Input) (Response, error) {
+	if err := g.api.InitClient(ctx); err != nil {
+		return Response{}, err
+	}
+	text, err := g.api.Invoke(ctx, inputs...)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{Text: text}, nil
+}
+
+func (g geminiLLM) InvokeStream(ctx context.Context, inputs ...Input) (<-chan StreamChunk, error) {
+	return g.api.InvokeStream(ctx, inputs...)
+}
+
+func (g geminiLLM) CountTokens(ctx context.Context, inputs ...Input) (int, error) {
+	if err := g.api.InitClient(ctx); err != nil {
+		return 0, err
+	}
+	model := g.api.Client.GenerativeModel(g.api.ModelName)
+	var parts []genai.Part
+	for _, input := range inputs {
+		part, err := g.api.resolvePart(ctx, input)
+		if err != nil {
+			return 0, err
+		}
+		parts = append(parts, part)
+	}
+	resp, err := model.CountTokens(ctx, parts...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tokens: %w", err)
+	}
+	return int(resp.TotalTokens), nil
+}
+
+func (g geminiLLM) Models() []string {
+	return []string{g.api.ModelName}
+}
+
+// claudeLLM 把 *ClaudeAPI 适配成 Provider 接口
+type claudeLLM struct{ api *ClaudeAPI }
+
+// AsProvider 把 ClaudeAPI 包装成满足 Provider 接口的实现
+func (a *ClaudeAPI) AsProvider() Provider { return claudeLLM{api: a} }
+
+func (c claudeLLM) Invoke(ctx context.Context, inputs ...Input) (Response, error) {
+	text, err := c.api.Invoke(ctx, inputsToPrompts(inputs), nil)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{Text: text}, nil
+}
+
+func (c claudeLLM) InvokeStream(ctx context.Context, inputs ...Input) (<-chan StreamChunk, error) {
+	return c.api.InvokeStream(ctx, inputsToPrompts(inputs), nil)
+}
+
+func (c claudeLLM) CountTokens(ctx context.Context, inputs ...Input) (int, error) {
+	return estimateTokens(inputsToPrompts(inputs)), nil
+}
+
+func (c claudeLLM) Models() []string {
+	return []string{c.api.Model}
+}
+
+// inputsToPrompts 抽取一组 Input 中的文本部分，供只接受 []string prompts 的 provider 使用
+func inputsToPrompts(inputs []Input) []string {
+	var prompts []string
+	for _, input := range inputs {
+		if t, ok := input.(TextInput); ok {
+			prompts = append(prompts, t.Text)
+		}
+	}
+	return prompts
+}
+
+// estimateTokens 在没有原生 tokenizer 时的粗略估算：英文约 4 字符一个 token
+func estimateTokens(prompts []string) int {
+	chars := 0
+	for _, p := range prompts {
+		chars += len(p)
+	}
+	return (chars + 3) / 4
+}
+
+// RoutePolicy 决定 Router 在多个 backend 中如何选择下一个调用目标
+type RoutePolicy int
+
+const (
+	// RoutePrimary 总是优先使用第一个 backend，失败时依次回退
+	RoutePrimary RoutePolicy = iota
+	// RouteRoundRobin 依次轮询所有 backend
+	RouteRoundRobin
+	// RouteWeighted 按 Backend.Weight 加权随机选择起始 backend，失败时依次回退
+	RouteWeighted
+	// RouteLatency 优先选择滚动平均延迟最低的 backend，失败时依次回退
+	RouteLatency
+)
+
+// Backend 是 Router 管理的一个可调用的 Provider 实例
+type Backend struct {
+	Name     string
+	Provider Provider
+	Weight   int // 仅在 RouteWeighted 下使用，默认视为 1
+
+	mu          sync.Mutex
+	failures    int
+	circuitOpen time.Time // 非零值表示熔断开启的时间，过了 breakerCooldown 才会重新尝试
+	avgLatency  time.Duration
+	rrCounter   uint64
+}
+
+// Router 按配置的 RoutePolicy 在多个 backend 间路由请求，并在 5xx/429/配额错误时自动回退
+type Router struct {
+	Backends         []*Backend
+	Policy           RoutePolicy
+	MaxConcurrency   int           // 0 表示不限制
+	BreakerThreshold int           // 连续失败多少次后熔断，0 表示使用默认值 5
+	BreakerCooldown  time.Duration // 熔断后多久允许重试，0 表示使用默认值 30s
+
+	sem     chan struct{}
+	rrIndex uint64
+}
+
+// NewRouter 创建一个 Router，按给定顺序注册 backend
+func NewRouter(policy RoutePolicy, backends ...*Backend) *Router {
+	r := &Router{Backends: backends, Policy: policy}
+	return r
+}
+
+func (r *Router) breakerThreshold() int {
+	if r.BreakerThreshold <= 0 {
+		return 5
+	}
+	return r.BreakerThreshold
+}
+
+func (r *Router) breakerCooldown() time.Duration {
+	if r.BreakerCooldown <= 0 {
+		return 30 * time.Second
+	}
+	return r.BreakerCooldown
+}
+
+func (r *Router) acquire(ctx context.Context) (func(), error) {
+	if r.MaxConcurrency <= 0 {
+		return func() {}, nil
+	}
+	if r.sem == nil {
+		r.sem = make(chan struct{}, r.MaxConcurrency)
+	}
+	select {
+	case r.sem <- struct{}{}:
+		return func() { <-r.sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// isAvailable 报告 backend 是否未处于熔断状态
+func (b *Backend) isAvailable(cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.circuitOpen.IsZero() {
+		return true
+	}
+	if time.Since(b.circuitOpen) > cooldown {
+		return true // half-open：允许一次试探性调用
+	}
+	return false
+}
+
+func (b *Backend) recordResult(err error, latency time.Duration, threshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		b.circuitOpen = time.Time{}
+		if b.avgLatency == 0 {
+			b.avgLatency = latency
+		} else {
+			b.avgLatency = (b.avgLatency + latency) / 2
+		}
+		return
+	}
+	b.failures++
+	if b.failures >= threshold {
+		b.circuitOpen = time.Now()
+	}
+}
+
+// order 返回按 Policy 排好顺序的候选 backend 列表（第一个失败时依次尝试后面的）
+func (r *Router) order() []*Backend {
+	available := make([]*Backend, 0, len(r.Backends))
+	for _, b := range r.Backends {
+		if b.isAvailable(r.breakerCooldown()) {
+			available = append(available, b)
+		}
+	}
+	if len(available) == 0 {
+		available = r.Backends // 全部熔断时仍然尝试一次，避免彻底不可用
+	}
+	if len(available) == 0 {
+		return nil
+	}
+
+	switch r.Policy {
+	case RouteRoundRobin:
+		start := atomic.AddUint64(&r.rrIndex, 1) % uint64(len(available))
+		return append(available[start:], available[:start]...)
+	case RouteLatency:
+		sorted := append([]*Backend{}, available...)
+		for i := 1; i < len(sorted); i++ {
+			for j := i; j > 0 && sorted[j].avgLatency < sorted[j-1].avgLatency && sorted[j].avgLatency > 0; j-- {
+				sorted[j], sorted[j-1] = sorted[j-1], sorted[j]
+			}
+		}
+		return sorted
+	case RouteWeighted:
+		// 按 Weight 加权随机选出起始 backend，其余按原始注册顺序依次作为回退
+		totalWeight := 0
+		for _, b := range available {
+			totalWeight += weightOf(b)
+		}
+		pick := rand.Intn(totalWeight)
+		startIdx := 0
+		for i, b := range available {
+			pick -= weightOf(b)
+			if pick < 0 {
+				startIdx = i
+				break
+			}
+		}
+		ordered := make([]*Backend, 0, len(available))
+		ordered = append(ordered, available[startIdx])
+		ordered = append(ordered, available[:startIdx]...)
+		ordered = append(ordered, available[startIdx+1:]...)
+		return ordered
+	default: // RoutePrimary
+		return available
+	}
+}
+
+func weightOf(b *Backend) int {
+	if b.Weight <= 0 {
+		return 1
+	}
+	return b.Weight
+}
+
+// serverErrorPattern 匹配错误消息里作为独立数字出现的 5xx 状态码
+var serverErrorPattern = regexp.MustCompile(`\b5\d\d\b`)
+
+// isRetryableRouteError 判断是否应该回退到下一个 backend
+func isRetryableRouteError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "429") ||
+		strings.Contains(msg, "resource_exhausted") ||
+		strings.Contains(msg, "quota") ||
+		serverErrorPattern.MatchString(msg)
+}
+
+// Invoke 依照 Policy 选择 backend 调用，单个 backend 失败且错误可重试时自动回退到下一个
+func (r *Router) Invoke(ctx context.Context, inputs ...Input) (Response, error) {
+	release, err := r.acquire(ctx)
+	if err != nil {
+		return Response{}, err
+	}
+	defer release()
+
+	var lastErr error
+	for _, b := range r.order() {
+		start := time.Now()
+		resp, err := b.Provider.Invoke(ctx, inputs...)
+		b.recordResult(err, time.Since(start), r.breakerThreshold())
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if !isRetryableRouteError(err) {
+			return Response{}, fmt.Errorf("backend %q failed: %w", b.Name, err)
+		}
+	}
+	if lastErr == nil {
+		return Response{}, errors.New("no backend registered")
+	}
+	return Response{}, fmt.Errorf("all backends exhausted, last error: %w", lastErr)
+}
@@ -0,0 +1,86 @@
+package genai
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRouterOrderEmptyBackends(t *testing.T) {
+	r := NewRouter(RouteRoundRobin)
+	if got := r.order(); got != nil {
+		t.Errorf("order() on a router with no backends should return nil, got %v", got)
+	}
+}
+
+func TestRouterOrderRoundRobinRotates(t *testing.T) {
+	a := &Backend{Name: "a"}
+	b := &Backend{Name: "b"}
+	c := &Backend{Name: "c"}
+	r := NewRouter(RouteRoundRobin, a, b, c)
+
+	var starts []string
+	for i := 0; i < 3; i++ {
+		order := r.order()
+		if len(order) != 3 {
+			t.Fatalf("expected 3 backends in order, got %d", len(order))
+		}
+		starts = append(starts, order[0].Name)
+	}
+
+	// 三次调用应该轮流从不同的 backend 开始
+	if starts[0] == starts[1] && starts[1] == starts[2] {
+		t.Errorf("round robin did not rotate across calls: %v", starts)
+	}
+}
+
+func TestRouterOrderSkipsCircuitOpenBackend(t *testing.T) {
+	healthy := &Backend{Name: "healthy"}
+	broken := &Backend{Name: "broken"}
+	r := NewRouter(RoutePrimary, healthy, broken)
+
+	broken.recordResult(errors.New("boom"), time.Millisecond, 1)
+
+	order := r.order()
+	if len(order) != 1 || order[0].Name != "healthy" {
+		t.Errorf("expected only the healthy backend, got %v", order)
+	}
+}
+
+func TestRouterOrderWeightedDistributesProportionally(t *testing.T) {
+	heavy := &Backend{Name: "heavy", Weight: 9}
+	light := &Backend{Name: "light", Weight: 1}
+	r := NewRouter(RouteWeighted, heavy, light)
+
+	const trials = 2000
+	counts := map[string]int{}
+	for i := 0; i < trials; i++ {
+		order := r.order()
+		if len(order) != 2 {
+			t.Fatalf("expected 2 backends in order, got %d", len(order))
+		}
+		counts[order[0].Name]++
+	}
+
+	// heavy 的权重是 light 的 9 倍，统计上应该明显更常被选中；留出较宽的容差避免测试偶发失败
+	if counts["light"] == 0 {
+		t.Errorf("expected light to be picked at least occasionally, got %v", counts)
+	}
+	if counts["heavy"] <= counts["light"] {
+		t.Errorf("expected heavy (weight 9) to be picked far more often than light (weight 1), got %v", counts)
+	}
+}
+
+func TestRouterOrderAllCircuitOpenFallsBackToAll(t *testing.T) {
+	a := &Backend{Name: "a"}
+	b := &Backend{Name: "b"}
+	r := NewRouter(RoutePrimary, a, b)
+
+	a.recordResult(errors.New("boom"), time.Millisecond, 1)
+	b.recordResult(errors.New("boom"), time.Millisecond, 1)
+
+	order := r.order()
+	if len(order) != 2 {
+		t.Errorf("expected both backends as a last resort when all are circuit-open, got %v", order)
+	}
+}
@@ -0,0 +1,370 @@
+package genai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// StreamChunk 表示一次流式增量输出
+type StreamChunk struct {
+	Text            string          // 本次增量文本
+	FinishReason    string          // 结束原因，只有最后一个 chunk 会带值
+	ToolCallName    string          // 工具调用名称（如果本次增量包含函数调用片段）
+	ToolCallArgs    json.RawMessage // 工具调用参数片段
+	PromptTokens    int             // 截止目前的输入 token 数，只有最后一个 chunk 会带值
+	CandidateTokens int             // 截止目前的输出 token 数，只有最后一个 chunk 会带值
+	Err             error           // 非 nil 表示流中断
+}
+
+// InvokeStream 以流式方式调用 Gemini，增量结果通过 channel 返回
+func (a *GeminiAPI) InvokeStream(ctx context.Context, inputs ...Input) (<-chan StreamChunk, error) {
+	if err := a.InitClient(ctx); err != nil {
+		return nil, err
+	}
+
+	model := a.Client.GenerativeModel(a.ModelName)
+	model.SetTemperature(a.Temperature)
+	model.GenerationConfig.ResponseMIMEType = a.ResponseMIMEType
+	model.GenerationConfig.ResponseSchema = a.ResponseSchema
+
+	var parts []genai.Part
+	for _, input := range inputs {
+		part, err := a.resolvePart(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+
+	return a.streamGenerateContent(ctx, func(ctx context.Context) *genai.GenerateContentResponseIterator {
+		return model.GenerateContentStream(ctx, parts...)
+	}), nil
+}
+
+// invokeChatStream 和 InvokeStream 相同，但会把 history 之前的轮次重放给模型（通过 ChatSession），
+// 并在 systemInstruction 非空时把它设置到模型上
+func (a *GeminiAPI) invokeChatStream(ctx context.Context, systemInstruction string, history []ChatMessage, inputs ...Input) (<-chan StreamChunk, error) {
+	if err := a.InitClient(ctx); err != nil {
+		return nil, err
+	}
+
+	model := a.Client.GenerativeModel(a.ModelName)
+	model.SetTemperature(a.Temperature)
+	model.GenerationConfig.ResponseMIMEType = a.ResponseMIMEType
+	model.GenerationConfig.ResponseSchema = a.ResponseSchema
+	if systemInstruction != "" {
+		model.SystemInstruction = &genai.Content{Role: "user", Parts: []genai.Part{genai.Text(systemInstruction)}}
+	}
+
+	var parts []genai.Part
+	for _, input := range inputs {
+		part, err := a.resolvePart(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+
+	cs := model.StartChat()
+	baseHistory := chatHistoryToGeminiContent(history)
+	return a.streamGenerateContent(ctx, func(ctx context.Context) *genai.GenerateContentResponseIterator {
+		// 每次重启都从原始历史重放，避免 ChatSession.SendMessageStream 在失败的尝试里
+		// 把同一轮用户输入重复追加进 cs.History
+		cs.History = append([]*genai.Content{}, baseHistory...)
+		return cs.SendMessageStream(ctx, parts...)
+	}), nil
+}
+
+// streamGenerateContent 驱动一个 GenerateContentStream 迭代器并把增量转发到返回的 channel，
+// newIter 在失败后还没发出过任何增量时会被重新调用以整体重启流
+func (a *GeminiAPI) streamGenerateContent(ctx context.Context, newIter func(ctx context.Context) *genai.GenerateContentResponseIterator) <-chan StreamChunk {
+	out := make(chan StreamChunk)
+
+	go func() {
+		defer close(out)
+
+		chunksSent := 0
+		for attempt := 0; ; attempt++ {
+			iter := newIter(ctx)
+			streamErr := a.drainGeminiStream(ctx, iter, out, &chunksSent)
+			if streamErr == nil {
+				return
+			}
+
+			// 只有在本次尝试还没有发出过任何增量时才值得从头重启；
+			// 否则下游已经拿到了部分内容，重启会产生重复文本，直接把错误交给调用方处理。
+			if chunksSent > 0 || attempt >= a.MaxRetries {
+				select {
+				case out <- StreamChunk{Err: streamErr}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			if sleepErr := sleepWithContext(ctx, fullJitterBackoff(a.RetryDelay, attempt)); sleepErr != nil {
+				select {
+				case out <- StreamChunk{Err: sleepErr}:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// drainGeminiStream 把一个 GenerateContentStream 迭代器的增量转发到 out，chunksSent 记录本次
+// 尝试已经成功发出的 chunk 数，供调用方决定是否值得整体重启
+func (a *GeminiAPI) drainGeminiStream(ctx context.Context, iter *genai.GenerateContentResponseIterator, out chan<- StreamChunk, chunksSent *int) error {
+	for {
+		resp, err := iter.Next()
+		if err != nil {
+			if err.Error() == "no more items in iterator" {
+				return nil
+			}
+			return err
+		}
+
+		if len(resp.Candidates) == 0 {
+			continue
+		}
+		cand := resp.Candidates[0]
+		chunk := StreamChunk{}
+		if cand.FinishReason != genai.FinishReasonUnspecified {
+			chunk.FinishReason = cand.FinishReason.String()
+		}
+		if resp.UsageMetadata != nil {
+			chunk.PromptTokens = int(resp.UsageMetadata.PromptTokenCount)
+			chunk.CandidateTokens = int(resp.UsageMetadata.CandidatesTokenCount)
+		}
+		if cand.Content != nil {
+			for _, p := range cand.Content.Parts {
+				switch v := p.(type) {
+				case genai.Text:
+					chunk.Text += string(v)
+				case genai.FunctionCall:
+					chunk.ToolCallName = v.Name
+					if args, marshalErr := json.Marshal(v.Args); marshalErr == nil {
+						chunk.ToolCallArgs = args
+					}
+				}
+			}
+		}
+
+		select {
+		case out <- chunk:
+			*chunksSent++
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// InvokeStream 以流式方式调用 Claude（Vertex 的 streamRawPredict 接口），增量结果通过 channel 返回
+func (a *ClaudeAPI) InvokeStream(ctx context.Context, prompts []string, img_paths []string) (<-chan StreamChunk, error) {
+	messages, err := a.buildMessages(prompts, img_paths)
+	if err != nil {
+		return nil, err
+	}
+	return a.invokeChatStream(ctx, "", nil, messages)
+}
+
+// invokeChatStream 和 InvokeStream 相同，但会把 history 之前的轮次连同 systemInstruction
+// 一并拼进请求，供 ChatSession 使用
+func (a *ClaudeAPI) invokeChatStream(ctx context.Context, systemInstruction string, history []ChatMessage, messages []*Message) (<-chan StreamChunk, error) {
+	messages = append(chatHistoryToClaudeMessages(history), messages...)
+	if systemInstruction != "" {
+		messages = append([]*Message{{
+			Role:     "system",
+			Contents: []Contents{&ContentText{Type: "text", Text: systemInstruction}},
+		}}, messages...)
+	}
+
+	request, err := a.buildClaudeRequest(messages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	request.Stream = true
+
+	payloadBytes, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+
+		chunksSent := 0
+		for attempt := 0; ; attempt++ {
+			resp, err := a.streamClaudeModel(ctx, payloadBytes)
+			if err == nil {
+				err = a.drainClaudeStream(ctx, resp, out, &chunksSent)
+			}
+			if err == nil {
+				return
+			}
+
+			if chunksSent > 0 || attempt >= a.MaxRetries {
+				select {
+				case out <- StreamChunk{Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			if sleepErr := sleepWithContext(ctx, fullJitterBackoff(a.RetryDelay, attempt)); sleepErr != nil {
+				select {
+				case out <- StreamChunk{Err: sleepErr}:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// drainClaudeStream 把一次 streamRawPredict 响应的 SSE 事件转发到 out，chunksSent 记录本次
+// 尝试已经成功发出的 chunk 数，供调用方决定是否值得整体重启
+func (a *ClaudeAPI) drainClaudeStream(ctx context.Context, resp *http.Response, out chan<- StreamChunk, chunksSent *int) error {
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	var event string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			chunk, ok := parseClaudeStreamEvent(event, []byte(data))
+			if !ok {
+				continue
+			}
+			select {
+			case out <- chunk:
+				*chunksSent++
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case line == "":
+			event = ""
+		}
+	}
+	return scanner.Err()
+}
+
+// streamClaudeModel 向 streamRawPredict 端点发起请求，返回未关闭的响应体供调用方逐行读取
+func (a *ClaudeAPI) streamClaudeModel(ctx context.Context, payloadBytes []byte) (*http.Response, error) {
+	token, err := a.getAccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	apiURL := fmt.Sprintf(strings.Replace(apiURLFormat, ":rawPredict", ":streamRawPredict", 1), a.Location, a.ProjectID, a.Location, a.Model)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, strings.NewReader(string(payloadBytes)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// claudeStreamEvent 是 Anthropic streaming 协议中 content_block_delta / message_delta 的一个子集
+type claudeStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// parseClaudeStreamEvent 把一个 SSE 事件转成 StreamChunk，ok=false 表示该事件没有可用增量
+func parseClaudeStreamEvent(event string, data []byte) (StreamChunk, bool) {
+	if event != "content_block_delta" && event != "message_delta" {
+		return StreamChunk{}, false
+	}
+	var ev claudeStreamEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return StreamChunk{}, false
+	}
+
+	chunk := StreamChunk{}
+	switch ev.Delta.Type {
+	case "text_delta":
+		chunk.Text = ev.Delta.Text
+	case "input_json_delta":
+		chunk.ToolCallArgs = json.RawMessage(ev.Delta.PartialJSON)
+	}
+	if ev.Delta.StopReason != "" {
+		chunk.FinishReason = ev.Delta.StopReason
+	}
+	if ev.Usage.OutputTokens > 0 {
+		chunk.PromptTokens = ev.Usage.InputTokens
+		chunk.CandidateTokens = ev.Usage.OutputTokens
+	}
+	return chunk, true
+}
+
+// ServeSSE 将一个 StreamChunk channel 以 text/event-stream 的形式写回 http.ResponseWriter
+func ServeSSE(w http.ResponseWriter, r *http.Request, chunks <-chan StreamChunk) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming unsupported by response writer")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	for {
+		select {
+		case chunk, open := <-chunks:
+			if !open {
+				fmt.Fprint(w, "event: done\ndata: {}\n\n")
+				flusher.Flush()
+				return nil
+			}
+			if chunk.Err != nil {
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", chunk.Err.Error())
+				flusher.Flush()
+				return chunk.Err
+			}
+			payload, err := json.Marshal(chunk)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "data: %s\n\n", payload)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	}
+}
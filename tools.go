@@ -0,0 +1,432 @@
+package genai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// DefaultMaxToolIterations 是 InvokeWithTools 在 maxIterations<=0 时使用的最大模型调用轮数，
+// 达到上限仍没有文本答案时返回错误
+const DefaultMaxToolIterations = 5
+
+// ToolChoiceMode 控制模型是否必须调用工具、可以调用任意已声明工具，还是被禁止调用工具
+type ToolChoiceMode string
+
+const (
+	ToolChoiceAuto  ToolChoiceMode = "auto"  // 模型自行决定是否调用工具
+	ToolChoiceAny   ToolChoiceMode = "any"   // 模型必须调用某个已声明的工具
+	ToolChoiceNone  ToolChoiceMode = "none"  // 模型不能调用工具
+	ToolChoiceNamed ToolChoiceMode = "named" // 模型必须调用 Name 指定的工具
+)
+
+// ToolChoice 配置 InvokeWithTools 在一次调用中如何约束工具选择
+type ToolChoice struct {
+	Mode ToolChoiceMode
+	Name string // 仅在 Mode 为 ToolChoiceNamed 时使用
+}
+
+// Tool 描述一个可供模型调用的函数
+type Tool struct {
+	Name        string
+	Description string
+	Schema      *genai.Schema
+	Handler     func(ctx context.Context, args json.RawMessage) (any, error)
+}
+
+// toGenaiTool 把 Tool 切片转换成 Gemini SDK 需要的 FunctionDeclarations 形状
+func toGenaiTool(tools []Tool) *genai.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	decls := make([]*genai.FunctionDeclaration, 0, len(tools))
+	for _, t := range tools {
+		decls = append(decls, &genai.FunctionDeclaration{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  t.Schema,
+		})
+	}
+	return &genai.Tool{FunctionDeclarations: decls}
+}
+
+func findTool(tools []Tool, name string) (Tool, bool) {
+	for _, t := range tools {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Tool{}, false
+}
+
+// toGenaiToolConfig 把 ToolChoice 转换成 Gemini SDK 的 FunctionCallingConfig
+func toGenaiToolConfig(choice ToolChoice) *genai.ToolConfig {
+	switch choice.Mode {
+	case ToolChoiceAny:
+		return &genai.ToolConfig{FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: genai.FunctionCallingAny}}
+	case ToolChoiceNone:
+		return &genai.ToolConfig{FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: genai.FunctionCallingNone}}
+	case ToolChoiceNamed:
+		return &genai.ToolConfig{FunctionCallingConfig: &genai.FunctionCallingConfig{
+			Mode:                 genai.FunctionCallingAny,
+			AllowedFunctionNames: []string{choice.Name},
+		}}
+	default: // ToolChoiceAuto 或未设置
+		return nil
+	}
+}
+
+// InvokeWithTools 和 Invoke 类似，但允许模型在最终答案之前调用已注册的工具。
+// 每当模型返回 FunctionCall，InvokeWithTools 会执行对应的 Handler，把结果作为
+// functionResponse 回填给模型，直至模型给出文本答案或达到 maxIterations 上限。
+func (a *GeminiAPI) InvokeWithTools(ctx context.Context, tools []Tool, choice ToolChoice, maxIterations int, inputs ...Input) (string, error) {
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxToolIterations
+	}
+
+	if err := a.InitClient(ctx); err != nil {
+		return "", err
+	}
+
+	model := a.Client.GenerativeModel(a.ModelName)
+	model.SetTemperature(a.Temperature)
+	model.GenerationConfig.ResponseMIMEType = a.ResponseMIMEType
+	model.GenerationConfig.ResponseSchema = a.ResponseSchema
+	if genaiTool := toGenaiTool(tools); genaiTool != nil {
+		model.Tools = []*genai.Tool{genaiTool}
+	}
+	if toolConfig := toGenaiToolConfig(choice); toolConfig != nil {
+		model.ToolConfig = toolConfig
+	}
+
+	var parts []genai.Part
+	for _, input := range inputs {
+		part, err := a.resolvePart(ctx, input)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+
+	// 用 ChatSession 累积完整的对话轮次，每次都把原始问题和之前的工具往返一起发给模型，
+	// 而不是只发最后一轮，否则模型在第一次工具调用后就会丢失原始问题。
+	cs := model.StartChat()
+
+	for i := 0; i < maxIterations; i++ {
+		baseHistory := append([]*genai.Content{}, cs.History...)
+		sendParts := parts
+		resp, err := a.retryableGenerateContent(ctx, estimateTokens(partsToPrompts(sendParts)), func(ctx context.Context) (*genai.GenerateContentResponse, error) {
+			cs.History = append([]*genai.Content{}, baseHistory...)
+			return cs.SendMessage(ctx, sendParts...)
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to generate content: %w", err)
+		}
+		if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+			return "", fmt.Errorf("no response content found")
+		}
+
+		cand := resp.Candidates[0]
+		var functionCall *genai.FunctionCall
+		var textResult string
+		for _, p := range cand.Content.Parts {
+			switch v := p.(type) {
+			case genai.FunctionCall:
+				fc := v
+				functionCall = &fc
+			case genai.Text:
+				textResult += string(v)
+			}
+		}
+
+		if functionCall == nil {
+			return textResult, nil
+		}
+
+		tool, ok := findTool(tools, functionCall.Name)
+		if !ok {
+			return "", fmt.Errorf("model requested unknown tool %q", functionCall.Name)
+		}
+
+		argsJSON, err := json.Marshal(functionCall.Args)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal tool call args: %w", err)
+		}
+		result, err := tool.Handler(ctx, argsJSON)
+		if err != nil {
+			return "", fmt.Errorf("tool %q failed: %w", tool.Name, err)
+		}
+
+		resultMap, ok := result.(map[string]any)
+		if !ok {
+			resultMap = map[string]any{"result": result}
+		}
+
+		// cs.SendMessage 已经把这一轮的 user parts 和模型的 functionCall 回复记入 cs.History，
+		// 下一轮只需要把 functionResponse 作为新的一轮 parts 发过去
+		parts = []genai.Part{genai.FunctionResponse{Name: tool.Name, Response: resultMap}}
+	}
+
+	return "", fmt.Errorf("exceeded max tool iterations (%d) without a final answer", maxIterations)
+}
+
+// ClaudeTool 是 Anthropic Messages API 里的一条 tool 声明
+type ClaudeTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+// ClaudeToolUse 描述 Claude 返回的一次 tool_use 内容块
+type ClaudeToolUse struct {
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+}
+
+// toClaudeToolChoice 把 ToolChoice 转换成 Anthropic Messages API 的 tool_choice 形状：
+// {"type": "auto"|"any"|"none"|"tool", "name": "..."}
+func toClaudeToolChoice(choice ToolChoice) map[string]any {
+	switch choice.Mode {
+	case ToolChoiceAny:
+		return map[string]any{"type": "any"}
+	case ToolChoiceNone:
+		return map[string]any{"type": "none"}
+	case ToolChoiceNamed:
+		return map[string]any{"type": "tool", "name": choice.Name}
+	default: // ToolChoiceAuto 或未设置
+		return nil
+	}
+}
+
+func toClaudeTools(tools []Tool) []ClaudeTool {
+	out := make([]ClaudeTool, 0, len(tools))
+	for _, t := range tools {
+		out = append(out, ClaudeTool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: schemaToJSONSchema(t.Schema),
+		})
+	}
+	return out
+}
+
+// InvokeWithTools 是 Invoke 的工具调用版本：Claude 返回 tool_use 内容块时执行对应
+// Handler，并把结果以 tool_result 形式追加到对话历史，循环直至模型给出文本答案。
+func (a *ClaudeAPI) InvokeWithTools(ctx context.Context, tools []Tool, choice ToolChoice, maxIterations int, prompts []string, img_paths []string) (string, error) {
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxToolIterations
+	}
+
+	messages, err := a.buildMessages(prompts, img_paths)
+	if err != nil {
+		return "", err
+	}
+
+	claudeTools := toClaudeTools(tools)
+	claudeToolChoice := toClaudeToolChoice(choice)
+
+	for i := 0; i < maxIterations; i++ {
+		request, err := a.buildClaudeRequest(messages)
+		if err != nil {
+			return "", fmt.Errorf("failed to build request: %w", err)
+		}
+		request.Tools = claudeTools
+		request.ToolChoice = claudeToolChoice
+
+		payloadBytes, err := json.Marshal(request)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal request: %w", err)
+		}
+
+		body, err := a.callClaudeModel(ctx, payloadBytes)
+		if err != nil {
+			return "", fmt.Errorf("failed to call claude model: %w", err)
+		}
+
+		var resp ClaudeResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+
+		toolUse, ok := findClaudeToolUse(resp)
+		if !ok {
+			return parseClaudeResponseContent(resp)
+		}
+
+		result, err := invokeClaudeTool(ctx, tools, toolUse)
+		if err != nil {
+			return "", err
+		}
+
+		messages = append(messages,
+			&Message{Role: "assistant", Contents: toolUseContents(resp)},
+			&Message{Role: "user", Contents: []Contents{
+				map[string]any{
+					"type":        "tool_result",
+					"tool_use_id": toolUse.ID,
+					"content":     result,
+				},
+			}},
+		)
+	}
+
+	return "", fmt.Errorf("exceeded max tool iterations (%d) without a final answer", maxIterations)
+}
+
+func findClaudeToolUse(resp ClaudeResponse) (ClaudeToolUse, bool) {
+	for _, block := range resp.Content {
+		if block["type"] != "tool_use" {
+			continue
+		}
+		input, _ := json.Marshal(block["input"])
+		return ClaudeToolUse{
+			ID:    fmt.Sprint(block["id"]),
+			Name:  fmt.Sprint(block["name"]),
+			Input: input,
+		}, true
+	}
+	return ClaudeToolUse{}, false
+}
+
+func toolUseContents(resp ClaudeResponse) []Contents {
+	contents := make([]Contents, 0, len(resp.Content))
+	for _, block := range resp.Content {
+		contents = append(contents, block)
+	}
+	return contents
+}
+
+func invokeClaudeTool(ctx context.Context, tools []Tool, toolUse ClaudeToolUse) (string, error) {
+	tool, ok := findTool(tools, toolUse.Name)
+	if !ok {
+		return "", fmt.Errorf("model requested unknown tool %q", toolUse.Name)
+	}
+	result, err := tool.Handler(ctx, toolUse.Input)
+	if err != nil {
+		return "", fmt.Errorf("tool %q failed: %w", tool.Name, err)
+	}
+	out, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal tool result: %w", err)
+	}
+	return string(out), nil
+}
+
+// schemaToJSONSchema 把 Vertex genai.Schema 转换成 Claude tools[].input_schema 所需的
+// plain JSON Schema map，只覆盖 Tool 声明常用的字段。
+func schemaToJSONSchema(schema *genai.Schema) map[string]any {
+	if schema == nil {
+		return map[string]any{"type": "object"}
+	}
+	out := map[string]any{"type": "object"}
+	if len(schema.Properties) > 0 {
+		props := map[string]any{}
+		for name, prop := range schema.Properties {
+			props[name] = map[string]any{
+				"type":        jsonSchemaTypeName(prop.Type),
+				"description": prop.Description,
+			}
+		}
+		out["properties"] = props
+	}
+	if len(schema.Required) > 0 {
+		out["required"] = schema.Required
+	}
+	return out
+}
+
+// jsonSchemaTypeName 把 genai.Type 映射成 JSON Schema 的小写类型关键字（"string"、"number"、…）。
+// genai.Type.String() 返回的是 Go 的枚举名（"TypeString"），不是合法的 JSON Schema 类型，
+// 直接拿去用会导致 Claude 的 Messages API 拒绝或忽略整个 tool 声明。
+func jsonSchemaTypeName(t genai.Type) string {
+	switch t {
+	case genai.TypeString:
+		return "string"
+	case genai.TypeNumber:
+		return "number"
+	case genai.TypeInteger:
+		return "integer"
+	case genai.TypeBoolean:
+		return "boolean"
+	case genai.TypeArray:
+		return "array"
+	case genai.TypeObject:
+		return "object"
+	default:
+		return "string"
+	}
+}
+
+// claudeStructuredResultTool 是 InvokeInto 强制 Claude 调用的唯一工具名，借助 tool_choice
+// 的 named 模式把自由文本响应变成一次保证符合 ResponseSchema 的工具调用
+const claudeStructuredResultTool = "emit_result"
+
+// InvokeInto 调用前必须先用 WithResponseSchema/WithResponseStruct 设置 ResponseSchema。
+// 它强制 Claude 调用一个输入符合该 schema 的工具，把工具入参反序列化进 out；
+// 如果返回的 JSON 不满足 out 的类型，会把失败原因追加到对话并重试一次。
+func (a *ClaudeAPI) InvokeInto(ctx context.Context, out any, prompts []string, img_paths []string) (string, error) {
+	if a.ResponseSchema == nil {
+		return "", fmt.Errorf("claude response schema not set, call WithResponseSchema or WithResponseStruct first")
+	}
+
+	messages, err := a.buildMessages(prompts, img_paths)
+	if err != nil {
+		return "", err
+	}
+
+	claudeTools := []ClaudeTool{{
+		Name:        claudeStructuredResultTool,
+		Description: "Return the final structured result matching the required schema.",
+		InputSchema: schemaToJSONSchema(a.ResponseSchema),
+	}}
+	claudeToolChoice := map[string]any{"type": "tool", "name": claudeStructuredResultTool}
+
+	var lastText string
+	var unmarshalErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		request, err := a.buildClaudeRequest(messages)
+		if err != nil {
+			return "", fmt.Errorf("failed to build request: %w", err)
+		}
+		request.Tools = claudeTools
+		request.ToolChoice = claudeToolChoice
+
+		payloadBytes, err := json.Marshal(request)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal request: %w", err)
+		}
+
+		body, err := a.callClaudeModel(ctx, payloadBytes)
+		if err != nil {
+			return "", fmt.Errorf("failed to call claude model: %w", err)
+		}
+
+		var resp ClaudeResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+
+		toolUse, ok := findClaudeToolUse(resp)
+		if !ok {
+			return "", fmt.Errorf("model did not return the %q tool call", claudeStructuredResultTool)
+		}
+		lastText = string(toolUse.Input)
+
+		if unmarshalErr = json.Unmarshal(toolUse.Input, out); unmarshalErr == nil {
+			return lastText, nil
+		}
+
+		messages = append(messages,
+			&Message{Role: "assistant", Contents: toolUseContents(resp)},
+			&Message{Role: "user", Contents: []Contents{&ContentText{
+				Type: "text",
+				Text: fmt.Sprintf("Your previous %q call returned invalid JSON for the required schema. Please call it again with valid arguments.", claudeStructuredResultTool),
+			}}},
+		)
+	}
+
+	return lastText, fmt.Errorf("model returned invalid JSON after retry: %w", unmarshalErr)
+}
@@ -0,0 +1,112 @@
+package genai
+
+import (
+	"testing"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+func TestToGenaiToolConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		choice ToolChoice
+		want   genai.FunctionCallingMode
+		isNil  bool
+	}{
+		{"auto returns nil", ToolChoice{Mode: ToolChoiceAuto}, 0, true},
+		{"unset returns nil", ToolChoice{}, 0, true},
+		{"any", ToolChoice{Mode: ToolChoiceAny}, genai.FunctionCallingAny, false},
+		{"none", ToolChoice{Mode: ToolChoiceNone}, genai.FunctionCallingNone, false},
+		{"named", ToolChoice{Mode: ToolChoiceNamed, Name: "my_tool"}, genai.FunctionCallingAny, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toGenaiToolConfig(tt.choice)
+			if tt.isNil {
+				if got != nil {
+					t.Errorf("expected nil tool config, got %+v", got)
+				}
+				return
+			}
+			if got == nil || got.FunctionCallingConfig.Mode != tt.want {
+				t.Errorf("toGenaiToolConfig(%+v) = %+v, want mode %v", tt.choice, got, tt.want)
+			}
+		})
+	}
+
+	named := toGenaiToolConfig(ToolChoice{Mode: ToolChoiceNamed, Name: "my_tool"})
+	if len(named.FunctionCallingConfig.AllowedFunctionNames) != 1 || named.FunctionCallingConfig.AllowedFunctionNames[0] != "my_tool" {
+		t.Errorf("expected AllowedFunctionNames to contain my_tool, got %+v", named.FunctionCallingConfig.AllowedFunctionNames)
+	}
+}
+
+func TestSchemaToJSONSchema(t *testing.T) {
+	validTypes := map[string]bool{
+		"string": true, "number": true, "integer": true,
+		"boolean": true, "array": true, "object": true,
+	}
+
+	schema := &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"name":    {Type: genai.TypeString},
+			"count":   {Type: genai.TypeInteger},
+			"price":   {Type: genai.TypeNumber},
+			"active":  {Type: genai.TypeBoolean},
+			"tags":    {Type: genai.TypeArray},
+			"details": {Type: genai.TypeObject},
+		},
+		Required: []string{"name"},
+	}
+
+	out := schemaToJSONSchema(schema)
+	props, ok := out["properties"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected properties map, got %+v", out["properties"])
+	}
+
+	want := map[string]string{
+		"name": "string", "count": "integer", "price": "number",
+		"active": "boolean", "tags": "array", "details": "object",
+	}
+	for name, wantType := range want {
+		prop, ok := props[name].(map[string]any)
+		if !ok {
+			t.Fatalf("missing property %q in %+v", name, props)
+		}
+		gotType, _ := prop["type"].(string)
+		if gotType != wantType {
+			t.Errorf("property %q: type = %q, want %q", name, gotType, wantType)
+		}
+		if !validTypes[gotType] {
+			t.Errorf("property %q: type %q is not a valid JSON Schema type keyword", name, gotType)
+		}
+	}
+}
+
+func TestToClaudeToolChoice(t *testing.T) {
+	tests := []struct {
+		name   string
+		choice ToolChoice
+		want   map[string]any
+	}{
+		{"auto returns nil", ToolChoice{Mode: ToolChoiceAuto}, nil},
+		{"any", ToolChoice{Mode: ToolChoiceAny}, map[string]any{"type": "any"}},
+		{"none", ToolChoice{Mode: ToolChoiceNone}, map[string]any{"type": "none"}},
+		{"named", ToolChoice{Mode: ToolChoiceNamed, Name: "my_tool"}, map[string]any{"type": "tool", "name": "my_tool"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toClaudeToolChoice(tt.choice)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("expected nil, got %+v", got)
+				}
+				return
+			}
+			if got["type"] != tt.want["type"] || got["name"] != tt.want["name"] {
+				t.Errorf("toClaudeToolChoice(%+v) = %+v, want %+v", tt.choice, got, tt.want)
+			}
+		})
+	}
+}
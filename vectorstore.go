@@ -0,0 +1,162 @@
+package genai
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// VectorRecord 是存入 VectorStore 的一条向量记录
+type VectorRecord struct {
+	ID       string
+	Vector   []float32
+	Metadata map[string]string
+	Text     string // 原始段落文本，RAG 检索时用于拼装 prompt
+}
+
+// ScoredRecord 是 Query 返回的一条结果。MemoryVectorStore 的 Score 为余弦相似度（越大越相似）；
+// RedisVectorStore 的 Score 是 RediSearch KNN 返回的向量距离（越小越相似）
+type ScoredRecord struct {
+	VectorRecord
+	Score float32
+}
+
+// Filter 用于在 Query 时按元数据过滤候选记录
+type Filter func(metadata map[string]string) bool
+
+// VectorStore 是向量存储的统一接口
+type VectorStore interface {
+	Upsert(ctx context.Context, records []VectorRecord) error
+	Query(ctx context.Context, vector []float32, k int, filter Filter) ([]ScoredRecord, error)
+	Delete(ctx context.Context, ids []string) error
+}
+
+// MemoryVectorStore 是一个进程内、暴力扫描的 VectorStore 实现。
+// 规模不大（几万条以内）时用暴力余弦相似度搜索即可满足延迟要求，充当 HNSW 索引的简化替代。
+type MemoryVectorStore struct {
+	records map[string]VectorRecord
+}
+
+// NewMemoryVectorStore 创建一个进程内 VectorStore
+func NewMemoryVectorStore() *MemoryVectorStore {
+	return &MemoryVectorStore{records: make(map[string]VectorRecord)}
+}
+
+func (s *MemoryVectorStore) Upsert(ctx context.Context, records []VectorRecord) error {
+	for _, r := range records {
+		if r.ID == "" {
+			return fmt.Errorf("vector record missing ID")
+		}
+		s.records[r.ID] = r
+	}
+	return nil
+}
+
+func (s *MemoryVectorStore) Query(ctx context.Context, vector []float32, k int, filter Filter) ([]ScoredRecord, error) {
+	scored := make([]ScoredRecord, 0, len(s.records))
+	for _, r := range s.records {
+		if filter != nil && !filter(r.Metadata) {
+			continue
+		}
+		scored = append(scored, ScoredRecord{VectorRecord: r, Score: cosineSimilarity(vector, r.Vector)})
+	}
+
+	sort.Slice(scored, func(i, j int) bool { return scored[i].Score > scored[j].Score })
+	if k > 0 && k < len(scored) {
+		scored = scored[:k]
+	}
+	return scored, nil
+}
+
+func (s *MemoryVectorStore) Delete(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		delete(s.records, id)
+	}
+	return nil
+}
+
+func cosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
+// RAG 把 EmbeddingAPI、VectorStore 和 GeminiAPI 串起来，实现检索增强生成
+type RAG struct {
+	Embeddings     *EmbeddingAPI
+	Store          VectorStore
+	Gemini         *GeminiAPI
+	TopK           int    // 0 表示默认取 4
+	PromptTemplate string // 必须包含 "%s"（context）和 "%s"（question）两个占位符，默认模板见 NewRAG
+}
+
+const defaultRAGPromptTemplate = "Answer the question using only the context below.\n\nContext:\n%s\n\nQuestion: %s"
+
+// NewRAG 创建一个 RAG 助手，使用默认 prompt 模板和 TopK=4
+func NewRAG(embeddings *EmbeddingAPI, store VectorStore, gemini *GeminiAPI) *RAG {
+	return &RAG{
+		Embeddings:     embeddings,
+		Store:          store,
+		Gemini:         gemini,
+		TopK:           4,
+		PromptTemplate: defaultRAGPromptTemplate,
+	}
+}
+
+// IndexPassages 把一批段落文本 embedding 后写入 VectorStore
+func (r *RAG) IndexPassages(ctx context.Context, ids []string, passages []string) error {
+	if len(ids) != len(passages) {
+		return fmt.Errorf("ids and passages must have the same length")
+	}
+	vectors, err := r.Embeddings.Embed(ctx, passages)
+	if err != nil {
+		return fmt.Errorf("failed to embed passages: %w", err)
+	}
+
+	records := make([]VectorRecord, len(passages))
+	for i := range passages {
+		records[i] = VectorRecord{ID: ids[i], Vector: vectors[i], Text: passages[i]}
+	}
+	return r.Store.Upsert(ctx, records)
+}
+
+// Query 检索与 query 最相关的段落，拼装进 PromptTemplate 后调用 Gemini 生成回答
+func (r *RAG) Query(ctx context.Context, query string) (string, error) {
+	vectors, err := r.Embeddings.Embed(ctx, []string{query})
+	if err != nil {
+		return "", fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	topK := r.TopK
+	if topK <= 0 {
+		topK = 4
+	}
+	results, err := r.Store.Query(ctx, vectors[0], topK, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to query vector store: %w", err)
+	}
+
+	var contextText string
+	for _, res := range results {
+		contextText += res.Text + "\n\n"
+	}
+
+	template := r.PromptTemplate
+	if template == "" {
+		template = defaultRAGPromptTemplate
+	}
+	prompt := fmt.Sprintf(template, contextText, query)
+
+	return r.Gemini.Invoke(ctx, TextInput{Text: prompt})
+}
@@ -0,0 +1,175 @@
+package genai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisVectorStore 用 RediSearch 的向量索引（FT.SEARCH KNN）实现 VectorStore，
+// 适合需要跨实例共享、且记录规模较大到内存暴力扫描不再合适的场景。
+type RedisVectorStore struct {
+	Client    *redis.Client
+	IndexName string
+	Prefix    string // 存储记录的 key 前缀，默认 "genai:vec:"
+}
+
+// NewRedisVectorStore 创建一个 Redis 支持的 VectorStore，IndexName 对应一个已通过
+// FT.CREATE 建好的 RediSearch 向量索引
+func NewRedisVectorStore(client *redis.Client, indexName string) *RedisVectorStore {
+	return &RedisVectorStore{Client: client, IndexName: indexName, Prefix: "genai:vec:"}
+}
+
+func (s *RedisVectorStore) key(id string) string {
+	return s.Prefix + id
+}
+
+func (s *RedisVectorStore) Upsert(ctx context.Context, records []VectorRecord) error {
+	pipe := s.Client.Pipeline()
+	for _, r := range records {
+		metadata, err := json.Marshal(r.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		pipe.HSet(ctx, s.key(r.ID), map[string]any{
+			"vector":   float32SliceToBytes(r.Vector),
+			"text":     r.Text,
+			"metadata": metadata,
+		})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to upsert vectors: %w", err)
+	}
+	return nil
+}
+
+// Query 通过 FT.SEARCH ... KNN 对 IndexName 做近似最近邻检索，Score 为 KNN 距离，结果按距离升序排列。
+// filter 在结果返回后于客户端应用，
+// 因为 RediSearch 的元数据过滤语法和本包的 Filter 签名不直接对应。
+//
+// go-redis v9 没有内置的 RediSearch 命令支持（没有 FTSearchWithArgs/FTSearchOptions），
+// 所以这里用 Client.Do 直接发 FT.SEARCH 原始命令，自己解析返回的扁平数组。
+func (s *RedisVectorStore) Query(ctx context.Context, vector []float32, k int, filter Filter) ([]ScoredRecord, error) {
+	query := fmt.Sprintf("*=>[KNN %d @vector $vec AS score]", k)
+
+	res, err := s.Client.Do(ctx, "FT.SEARCH", s.IndexName, query,
+		"PARAMS", "2", "vec", float32SliceToBytes(vector),
+		"SORTBY", "score",
+		"RETURN", "4", "text", "metadata", "score", "vector",
+		"DIALECT", "2",
+	).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query redis vector index: %w", err)
+	}
+
+	docs, err := parseFTSearchReply(res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse redis vector index reply: %w", err)
+	}
+
+	out := make([]ScoredRecord, 0, len(docs))
+	for _, doc := range docs {
+		var metadata map[string]string
+		if raw, ok := doc.fields["metadata"]; ok {
+			_ = json.Unmarshal([]byte(raw), &metadata)
+		}
+		if filter != nil && !filter(metadata) {
+			continue
+		}
+
+		var score float32
+		if raw, ok := doc.fields["score"]; ok {
+			parsed, _ := strconv.ParseFloat(raw, 32)
+			score = float32(parsed)
+		}
+
+		record := ScoredRecord{
+			VectorRecord: VectorRecord{
+				ID:       doc.id,
+				Text:     doc.fields["text"],
+				Vector:   bytesToFloat32Slice([]byte(doc.fields["vector"])),
+				Metadata: metadata,
+			},
+			Score: score,
+		}
+		out = append(out, record)
+	}
+	return out, nil
+}
+
+// ftSearchDoc 是 FT.SEARCH 原始回复里一条命中记录：doc id 加上 RETURN 请求的字段
+type ftSearchDoc struct {
+	id     string
+	fields map[string]string
+}
+
+// parseFTSearchReply 把 FT.SEARCH 的扁平回复（[total, id1, [field1, value1, ...], id2, ...]）
+// 解析成结构化的 ftSearchDoc 列表
+func parseFTSearchReply(reply any) ([]ftSearchDoc, error) {
+	top, ok := reply.([]any)
+	if !ok || len(top) == 0 {
+		return nil, nil
+	}
+
+	var docs []ftSearchDoc
+	for i := 1; i+1 < len(top); i += 2 {
+		id, ok := top[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected doc id type %T", top[i])
+		}
+		fieldPairs, ok := top[i+1].([]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected fields type %T", top[i+1])
+		}
+
+		fields := make(map[string]string, len(fieldPairs)/2)
+		for j := 0; j+1 < len(fieldPairs); j += 2 {
+			key, _ := fieldPairs[j].(string)
+			value, _ := fieldPairs[j+1].(string)
+			fields[key] = value
+		}
+		docs = append(docs, ftSearchDoc{id: id, fields: fields})
+	}
+	return docs, nil
+}
+
+func (s *RedisVectorStore) Delete(ctx context.Context, ids []string) error {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.key(id)
+	}
+	if err := s.Client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to delete vectors: %w", err)
+	}
+	return nil
+}
+
+func float32SliceToBytes(vec []float32) []byte {
+	buf := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		bits := math.Float32bits(v)
+		buf[i*4+0] = byte(bits)
+		buf[i*4+1] = byte(bits >> 8)
+		buf[i*4+2] = byte(bits >> 16)
+		buf[i*4+3] = byte(bits >> 24)
+	}
+	return buf
+}
+
+// bytesToFloat32Slice 是 float32SliceToBytes 的逆操作，用于把 RediSearch 返回的原始
+// vector 字段还原成 []float32；输入长度不是 4 的倍数时返回 nil。
+func bytesToFloat32Slice(b []byte) []float32 {
+	if len(b) == 0 || len(b)%4 != 0 {
+		return nil
+	}
+	out := make([]float32, len(b)/4)
+	for i := range out {
+		bits := uint32(b[i*4+0]) | uint32(b[i*4+1])<<8 | uint32(b[i*4+2])<<16 | uint32(b[i*4+3])<<24
+		out[i] = math.Float32frombits(bits)
+	}
+	return out
+}
@@ -0,0 +1,30 @@
+package genai
+
+import "testing"
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float32
+	}{
+		{"identical vectors", []float32{1, 0, 0}, []float32{1, 0, 0}, 1},
+		{"orthogonal vectors", []float32{1, 0}, []float32{0, 1}, 0},
+		{"opposite vectors", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"zero vector", []float32{0, 0}, []float32{1, 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cosineSimilarity(tt.a, tt.b); abs32(got-tt.want) > 1e-6 {
+				t.Errorf("cosineSimilarity(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func abs32(f float32) float32 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}